@@ -0,0 +1,357 @@
+// Package asm implements an assembler for the virtual machine.
+//
+// It understands the subset of the Netwide Assembler (nasm) syntax used by the
+// programs in the examples directory, with the macros from vm.inc and the
+// instructions registered in package isa built in:
+//
+//	%include "vm.inc"
+//	[org 0x0]
+//
+//	  vset r0, 'A'
+//	loop:
+//	  voutb 0x20, r0
+//	  vjmp loop
+//
+//	data:
+//	  db "Hello", 0xa, 0
+//	  times 16 db 0
+//
+// Supported directives are org, db, dw, dd, times, equ and %define. Labels
+// starting with a dot are local to the preceding label.
+package asm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bartekpacia/toyvm/isa"
+)
+
+var ErrSyntax = errors.New("syntax error")
+
+// Assemble assembles the source and returns the machine code.
+func Assemble(src []byte) ([]byte, error) {
+	a := &assembler{
+		symbols: make(map[string]int),
+		defines: make(map[string]string),
+	}
+
+	lines := strings.Split(string(src), "\n")
+	for pass := 1; pass <= 2; pass++ {
+		a.pass = pass
+		a.out.Reset()
+		a.org = 0
+		a.global = ""
+		for i, line := range lines {
+			a.line = i + 1
+			err := a.assembleLine(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", a.line, err)
+			}
+		}
+	}
+
+	return a.out.Bytes(), nil
+}
+
+type assembler struct {
+	pass    int
+	line    int
+	org     int
+	global  string // last non-local label
+	symbols map[string]int
+	defines map[string]string
+	out     bytes.Buffer
+}
+
+// addr returns the address of the next emitted byte.
+func (a *assembler) addr() int {
+	return a.org + a.out.Len()
+}
+
+func (a *assembler) assembleLine(line string) error {
+	line = strings.TrimSpace(stripComment(line))
+	if line == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+		line = strings.TrimSpace(line[1 : len(line)-1])
+	}
+
+	if strings.HasPrefix(line, "%") {
+		return a.preprocessor(line)
+	}
+
+	// A label is either terminated with a colon or followed by equ.
+	word, rest := splitWord(line)
+	if strings.HasSuffix(word, ":") {
+		err := a.define(strings.TrimSuffix(word, ":"), a.addr())
+		if err != nil {
+			return err
+		}
+
+		word, rest = splitWord(rest)
+	} else if next, value := splitWord(rest); strings.EqualFold(next, "equ") {
+		v, err := a.eval(value)
+		if err != nil {
+			return err
+		}
+
+		return a.define(word, v)
+	}
+
+	if word == "" {
+		return nil
+	}
+
+	return a.statement(strings.ToLower(word), rest)
+}
+
+func (a *assembler) preprocessor(line string) error {
+	directive, rest := splitWord(line)
+	switch strings.ToLower(directive) {
+	case "%include":
+		name := strings.Trim(strings.TrimSpace(rest), `"'<>`)
+		if name != "vm.inc" {
+			return fmt.Errorf("%w: cannot include %q", ErrSyntax, name)
+		}
+
+		return nil
+	case "%define":
+		name, value := splitWord(rest)
+		if name == "" {
+			return fmt.Errorf("%w: %%define without a name", ErrSyntax)
+		}
+
+		a.defines[name] = value
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown directive %s", ErrSyntax, directive)
+	}
+}
+
+func (a *assembler) define(label string, value int) error {
+	if strings.HasPrefix(label, ".") {
+		if a.global == "" {
+			return fmt.Errorf("%w: local label %s without a preceding label", ErrSyntax, label)
+		}
+
+		label = a.global + label
+	} else {
+		a.global = label
+	}
+
+	if _, ok := isa.Registers[strings.ToLower(label)]; ok {
+		return fmt.Errorf("%w: %s is a register", ErrSyntax, label)
+	}
+
+	if old, ok := a.symbols[label]; ok && a.pass == 1 {
+		return fmt.Errorf("%w: label %s redefined", ErrSyntax, label)
+	} else if ok && old != value {
+		return fmt.Errorf("%w: label %s changed value between passes", ErrSyntax, label)
+	}
+
+	a.symbols[label] = value
+	return nil
+}
+
+func (a *assembler) statement(word, rest string) error {
+	switch word {
+	case "org":
+		if a.out.Len() != 0 {
+			return fmt.Errorf("%w: org after code", ErrSyntax)
+		}
+
+		org, err := a.eval(rest)
+		if err != nil {
+			return err
+		}
+
+		a.org = org
+		return nil
+	case "times":
+		countExpr, statement := splitTimes(rest)
+		count, err := a.eval(countExpr)
+		if err != nil {
+			return err
+		}
+
+		if count < 0 {
+			return fmt.Errorf("%w: negative times count %d", ErrSyntax, count)
+		}
+
+		word, rest := splitWord(statement)
+		for range count {
+			err := a.statement(strings.ToLower(word), rest)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case "db", "dw", "dd":
+		return a.data(map[string]int{"db": 1, "dw": 2, "dd": 4}[word], rest)
+	}
+
+	in, ok := isa.Lookup(word)
+	if !ok {
+		return fmt.Errorf("%w: unknown instruction %s", ErrSyntax, word)
+	}
+
+	return a.instruction(word, in, rest)
+}
+
+func (a *assembler) data(size int, rest string) error {
+	for _, item := range splitOperands(rest) {
+		if size == 1 && len(item) > 3 && isQuoted(item) {
+			a.out.WriteString(item[1 : len(item)-1])
+			continue
+		}
+
+		value, err := a.operand(item)
+		if err != nil {
+			return err
+		}
+
+		a.emit(value, size)
+	}
+
+	return nil
+}
+
+func (a *assembler) instruction(mnemonic string, in isa.Instruction, rest string) error {
+	operands := splitOperands(rest)
+	if len(operands) != len(in.Operands) {
+		return fmt.Errorf("%w: %s takes %d operands, got %d", ErrSyntax, mnemonic, len(in.Operands), len(operands))
+	}
+
+	values := make([]int, len(operands))
+	for i, op := range operands {
+		value, err := a.operand(op)
+		if err != nil {
+			return err
+		}
+
+		values[i] = value
+	}
+
+	a.out.WriteByte(in.Opcode)
+	order := in.Order
+	if order == nil {
+		order = make([]int, len(in.Operands))
+		for i := range order {
+			order[i] = i
+		}
+	}
+
+	for _, i := range order {
+		kind, value := in.Operands[i], values[i]
+		if kind == isa.Rel16 {
+			// The jump is relative to the address of the next instruction.
+			value = value - (a.addr() + 2)
+		}
+
+		if a.pass == 2 && !kind.Fits(value) {
+			return fmt.Errorf("%w: operand %s of %s out of range", ErrSyntax, operands[i], mnemonic)
+		}
+
+		a.emit(value, kind.Size())
+	}
+
+	return nil
+}
+
+// operand evaluates an operand. In the first pass, forward references evaluate
+// to 0, since only the size of the code matters.
+func (a *assembler) operand(expr string) (int, error) {
+	value, err := a.eval(expr)
+	if err != nil && a.pass == 1 && errors.Is(err, errUndefined) {
+		return 0, nil
+	}
+
+	return value, err
+}
+
+func (a *assembler) emit(value int, size int) {
+	for i := range size {
+		a.out.WriteByte(byte(value >> (8 * i)))
+	}
+}
+
+// stripComment removes a comment, ignoring semicolons in quotes.
+func stripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ';':
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// splitWord splits s into the first whitespace-separated word and the rest.
+func splitWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexAny(s, " \t")
+	if i == -1 {
+		return s, ""
+	}
+
+	return s[:i], strings.TrimSpace(s[i:])
+}
+
+// splitTimes splits the argument of times into the count expression and the
+// repeated statement.
+func splitTimes(s string) (string, string) {
+	for _, directive := range []string{"db", "dw", "dd"} {
+		for _, sep := range []string{" ", "\t"} {
+			i := strings.Index(strings.ToLower(s), sep+directive+" ")
+			if i != -1 {
+				return s[:i], s[i+1:]
+			}
+		}
+	}
+
+	word, rest := splitWord(s)
+	return word, rest
+}
+
+// splitOperands splits s at commas outside of quotes.
+func splitOperands(s string) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+
+	var operands []string
+	var quote rune
+	start := 0
+	for i, c := range s {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			operands = append(operands, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(operands, strings.TrimSpace(s[start:]))
+}
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0]
+}
@@ -0,0 +1,104 @@
+package asm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	testCases := []struct {
+		desc string
+		src  string
+		want []byte
+	}{
+		{
+			desc: "instructions with registers and immediates",
+			src:  "vset r4, 0x1234\nvmov r1, r4\nvnot r5\nvoff",
+			want: []byte{0x01, 0x04, 0x34, 0x12, 0x00, 0x00, 0x00, 0x01, 0x04, 0x18, 0x05, 0xff},
+		},
+		{
+			desc: "operands are reordered like in vm.inc",
+			src:  "voutb 0x20, r2\nvcrl 0x110, r0",
+			want: []byte{0xf2, 0x02, 0x20, 0xf0, 0x00, 0x10, 0x01},
+		},
+		{
+			desc: "jumps are relative to the next instruction",
+			src:  "start:\n  vjmp end\n  vjz start\nend:",
+			want: []byte{0x40, 0x03, 0x00, 0x21, 0xfa, 0xff},
+		},
+		{
+			desc: "local labels and org",
+			src:  "[org 0x100]\na:\n.x: vset r0, .x\nb:\n.x: vset r0, .x",
+			want: []byte{0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x01, 0x00, 0x06, 0x01, 0x00, 0x00},
+		},
+		{
+			desc: "data, strings, times and comments",
+			src:  "db \"a;b\", 0 ; comment\ndw 'xy'\ndd 1\ntimes 12-($-$$) db 0xff",
+			want: []byte{'a', ';', 'b', 0, 'x', 'y', 1, 0, 0, 0, 0xff, 0xff},
+		},
+		{
+			desc: "signed jumps",
+			src:  "a: vjl a\nvjge a\nvjle a\nvjg a",
+			want: []byte{0x27, 0xfd, 0xff, 0x28, 0xfa, 0xff, 0x29, 0xf7, 0xff, 0x2a, 0xf4, 0xff},
+		},
+		{
+			desc: "extended arithmetic instructions",
+			src:  "vidiv r0, r1\nvsar r2, r3\nvadc r4, r5\nvmull r6, r7",
+			want: []byte{0x50, 0x00, 0x01, 0x52, 0x02, 0x03, 0x55, 0x04, 0x05, 0x57, 0x06, 0x07},
+		},
+		{
+			desc: "floating-point instructions",
+			src:  "vfadd r0, r1\nvfcmp r2, r3\nvitof r4\nvftoi r5",
+			want: []byte{0x70, 0x00, 0x01, 0x74, 0x02, 0x03, 0x75, 0x04, 0x76, 0x05},
+		},
+		{
+			desc: "16-bit, stack and displacement addressing",
+			src:  "vldsw r0, r1\nvstsp 8, r2\nvldo r3, r4, 0x10\nvsto r5, -4, r6",
+			want: []byte{0x07, 0x00, 0x01, 0x0a, 0x02, 0x08, 0x00, 0x80, 0x03, 0x04, 0x10, 0x00, 0x81, 0x05, 0x06, 0xfc, 0xff},
+		},
+		{
+			desc: "atomic instructions",
+			src:  "vcas r0, r1, r2\nvxchg r3, r4",
+			want: []byte{0x60, 0x00, 0x01, 0x02, 0x61, 0x03, 0x04},
+		},
+		{
+			desc: "defines and equ",
+			src:  "%include \"vm.inc\"\n%define port 0x20\nchar equ 'A' + 1\nvset r0, char\nvoutb port, r0",
+			want: []byte{0x01, 0x00, 'B', 0x00, 0x00, 0x00, 0xf2, 0x00, 0x20},
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := Assemble([]byte(tc.src))
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("%s: got % x, want % x", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	testCases := []struct {
+		desc string
+		src  string
+	}{
+		{desc: "unknown instruction", src: "vfoo r0"},
+		{desc: "wrong number of operands", src: "vmov r0"},
+		{desc: "register out of range", src: "vnot 16"},
+		{desc: "undefined label", src: "vjmp nowhere"},
+		{desc: "redefined label", src: "a:\na:"},
+		{desc: "unknown include", src: "%include \"other.inc\""},
+	}
+
+	for _, tc := range testCases {
+		_, err := Assemble([]byte(tc.src))
+		if !errors.Is(err, ErrSyntax) && !errors.Is(err, errUndefined) {
+			t.Errorf("%s: got %v, want an error", tc.desc, err)
+		}
+	}
+}
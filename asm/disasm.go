@@ -0,0 +1,92 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bartekpacia/toyvm/isa"
+)
+
+// Line is a disassembled instruction or, for a byte that is not the start of
+// a valid instruction, a db directive.
+type Line struct {
+	Addr int
+	Code []byte
+	Text string
+}
+
+// String formats the line like a listing: the address, the machine code and
+// the source.
+func (l Line) String() string {
+	return fmt.Sprintf("%04x  %-17s %s", l.Addr, fmt.Sprintf("% x", l.Code), l.Text)
+}
+
+// Disassemble disassembles the machine code loaded at the address org. The
+// result assembles back to the same code.
+func Disassemble(code []byte, org int) []Line {
+	var lines []Line
+	for pos := 0; pos < len(code); {
+		addr := org + pos
+		text, size := disassembleOne(code[pos:], addr)
+		if size == 0 {
+			text, size = fmt.Sprintf("db %#02x", code[pos]), 1
+		}
+
+		lines = append(lines, Line{Addr: addr, Code: code[pos : pos+size], Text: text})
+		pos += size
+	}
+
+	return lines
+}
+
+// disassembleOne disassembles the instruction at the start of code. It returns
+// a size of 0 if there is no valid instruction there.
+func disassembleOne(code []byte, addr int) (string, int) {
+	mnemonic, in, ok := isa.Decode(code[0])
+	if !ok {
+		return "", 0
+	}
+
+	size := in.Size()
+	if len(code) < size {
+		return "", 0
+	}
+
+	order := in.Order
+	if order == nil {
+		order = make([]int, len(in.Operands))
+		for i := range order {
+			order[i] = i
+		}
+	}
+
+	operands := make([]string, len(in.Operands))
+	pos := 1
+	for _, i := range order {
+		kind := in.Operands[i]
+		value := 0
+		for j := range kind.Size() {
+			value |= int(code[pos+j]) << (8 * j)
+		}
+		pos += kind.Size()
+
+		switch kind {
+		case isa.Reg:
+			if !kind.Fits(value) {
+				return "", 0
+			}
+			operands[i] = fmt.Sprintf("r%d", value)
+		case isa.Rel16:
+			// The jump is relative to the address of the next instruction.
+			operands[i] = fmt.Sprintf("0x%04x", (addr+size+int(int16(value)))&0xffff)
+		default:
+			operands[i] = fmt.Sprintf("%#x", value)
+		}
+	}
+
+	if len(operands) == 0 {
+		return mnemonic, size
+	}
+
+	return mnemonic + " " + strings.Join(operands, ", "), size
+}
@@ -0,0 +1,84 @@
+package asm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bartekpacia/toyvm/isa"
+)
+
+func TestDisassemble(t *testing.T) {
+	code := []byte{
+		0x01, 0x00, 0x34, 0x12, 0x00, 0x00, // vset r0, 0x1234
+		0x21, 0xf7, 0xff, // vjz to the vset
+		0x81, 0x05, 0x06, 0xfc, 0xff, // vsto r5, 0xfffc, r6
+		0xf2, 0x02, 0x20, // voutb 0x20, r2
+		0xee,       // not an opcode
+		0x00, 0x10, // vmov with an invalid register
+		0x10, // truncated vadd
+	}
+	want := []string{
+		"0100  01 00 34 12 00 00 vset r0, 0x1234",
+		"0106  21 f7 ff          vjz 0x0100",
+		"0109  81 05 06 fc ff    vsto r5, 0xfffc, r6",
+		"010e  f2 02 20          voutb 0x20, r2",
+		"0111  ee                db 0xee",
+		"0112  00                db 0x00",
+		"0113  10                db 0x10",
+		"0114  10                db 0x10",
+	}
+
+	var got []string
+	for _, line := range Disassemble(code, 0x100) {
+		got = append(got, line.String())
+	}
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestDisassembleRoundTrip checks that every instruction assembles back to
+// the same code after disassembling.
+func TestDisassembleRoundTrip(t *testing.T) {
+	var src strings.Builder
+	for _, mnemonic := range isa.Mnemonics() {
+		in, _ := isa.Lookup(mnemonic)
+		var operands []string
+		for i, kind := range in.Operands {
+			switch kind {
+			case isa.Reg:
+				operands = append(operands, fmt.Sprintf("r%d", i+1))
+			case isa.Rel16:
+				operands = append(operands, "$")
+			default:
+				operands = append(operands, fmt.Sprintf("%d", 0x12+i))
+			}
+		}
+
+		fmt.Fprintf(&src, "%s %s\n", mnemonic, strings.Join(operands, ", "))
+	}
+
+	code, err := Assemble([]byte(src.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var listing strings.Builder
+	for _, line := range Disassemble(code, 0) {
+		if strings.HasPrefix(line.Text, "db ") {
+			t.Errorf("%04x: opcode %#02x not disassembled", line.Addr, line.Code[0])
+		}
+		fmt.Fprintln(&listing, line.Text)
+	}
+
+	got, err := Assemble([]byte(listing.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, code) {
+		t.Errorf("got % x, want % x", got, code)
+	}
+}
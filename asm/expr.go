@@ -0,0 +1,242 @@
+package asm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bartekpacia/toyvm/isa"
+)
+
+var errUndefined = errors.New("undefined symbol")
+
+// eval evaluates an integer expression. It supports numbers, characters,
+// symbols, $ (the current address), $$ (the origin), parentheses, unary minus
+// and the binary +, -, *, /, <<, >>, &, | operators.
+func (a *assembler) eval(expr string) (int, error) {
+	p := &parser{a: a, s: strings.TrimSpace(expr)}
+	value, err := p.expr(0)
+	if err != nil {
+		return 0, err
+	}
+
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return 0, fmt.Errorf("%w: unexpected %q in expression %q", ErrSyntax, p.s[p.pos:], expr)
+	}
+
+	return value, nil
+}
+
+type parser struct {
+	a     *assembler
+	s     string
+	pos   int
+	depth int // of %define expansion
+}
+
+// binary operators by precedence, lowest first
+var precedence = [][]string{
+	{"|"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+func (p *parser) expr(level int) (int, error) {
+	if level == len(precedence) {
+		return p.unary()
+	}
+
+	left, err := p.expr(level + 1)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		p.skipSpace()
+		op := ""
+		for _, candidate := range precedence[level] {
+			if strings.HasPrefix(p.s[p.pos:], candidate) {
+				op = candidate
+				break
+			}
+		}
+
+		if op == "" {
+			return left, nil
+		}
+
+		p.pos += len(op)
+		right, err := p.expr(level + 1)
+		if err != nil {
+			return 0, err
+		}
+
+		switch op {
+		case "|":
+			left |= right
+		case "&":
+			left &= right
+		case "<<":
+			left <<= right
+		case ">>":
+			left >>= right
+		case "+":
+			left += right
+		case "-":
+			left -= right
+		case "*":
+			left *= right
+		case "/":
+			if right == 0 {
+				return 0, fmt.Errorf("%w: division by zero", ErrSyntax)
+			}
+			left /= right
+		}
+	}
+}
+
+func (p *parser) unary() (int, error) {
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return 0, fmt.Errorf("%w: unexpected end of expression %q", ErrSyntax, p.s)
+	}
+
+	switch c := p.s[p.pos]; {
+	case c == '-':
+		p.pos++
+		value, err := p.unary()
+		return -value, err
+	case c == '~':
+		p.pos++
+		value, err := p.unary()
+		return ^value, err
+	case c == '(':
+		p.pos++
+		value, err := p.expr(0)
+		if err != nil {
+			return 0, err
+		}
+
+		p.skipSpace()
+		if p.pos == len(p.s) || p.s[p.pos] != ')' {
+			return 0, fmt.Errorf("%w: missing ) in expression %q", ErrSyntax, p.s)
+		}
+
+		p.pos++
+		return value, nil
+	case c == '\'' || c == '"':
+		end := strings.IndexByte(p.s[p.pos+1:], c)
+		if end == -1 {
+			return 0, fmt.Errorf("%w: unterminated string in expression %q", ErrSyntax, p.s)
+		}
+
+		chars := p.s[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		value := 0
+		for i := len(chars) - 1; i >= 0; i-- {
+			value = value<<8 | int(chars[i])
+		}
+
+		return value, nil
+	case c == '$':
+		if strings.HasPrefix(p.s[p.pos:], "$$") {
+			p.pos += 2
+			return p.a.org, nil
+		}
+
+		p.pos++
+		return p.a.addr(), nil
+	case c >= '0' && c <= '9':
+		return p.number()
+	default:
+		return p.symbol()
+	}
+}
+
+func (p *parser) number() (int, error) {
+	start := p.pos
+	for p.pos < len(p.s) && isIdentChar(rune(p.s[p.pos])) {
+		p.pos++
+	}
+
+	lit := strings.ToLower(strings.ReplaceAll(p.s[start:p.pos], "_", ""))
+	var value int64
+	var err error
+	switch {
+	case strings.HasPrefix(lit, "0x"):
+		value, err = strconv.ParseInt(lit[2:], 16, 64)
+	case strings.HasPrefix(lit, "0b"):
+		value, err = strconv.ParseInt(lit[2:], 2, 64)
+	case strings.HasSuffix(lit, "h"):
+		value, err = strconv.ParseInt(lit[:len(lit)-1], 16, 64)
+	default:
+		value, err = strconv.ParseInt(lit, 10, 64)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid number %s", ErrSyntax, lit)
+	}
+
+	return int(value), nil
+}
+
+func (p *parser) symbol() (int, error) {
+	start := p.pos
+	for p.pos < len(p.s) && (isIdentChar(rune(p.s[p.pos])) || p.s[p.pos] == '.') {
+		p.pos++
+	}
+
+	name := p.s[start:p.pos]
+	if name == "" {
+		return 0, fmt.Errorf("%w: unexpected %q in expression %q", ErrSyntax, p.s[p.pos:], p.s)
+	}
+
+	if strings.HasPrefix(name, ".") {
+		name = p.a.global + name
+	}
+
+	if value, ok := p.a.defines[name]; ok {
+		if p.depth > 16 {
+			return 0, fmt.Errorf("%w: recursive %%define %s", ErrSyntax, name)
+		}
+
+		sub := &parser{a: p.a, s: value, depth: p.depth + 1}
+		v, err := sub.expr(0)
+		if err != nil {
+			return 0, err
+		}
+
+		sub.skipSpace()
+		if sub.pos != len(sub.s) {
+			return 0, fmt.Errorf("%w: invalid %%define %s", ErrSyntax, name)
+		}
+
+		return v, nil
+	}
+
+	if value, ok := isa.Registers[strings.ToLower(name)]; ok {
+		return value, nil
+	}
+
+	value, ok := p.a.symbols[name]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", errUndefined, name)
+	}
+
+	return value, nil
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func isIdentChar(c rune) bool {
+	return c == '_' || c == '?' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
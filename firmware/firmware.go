@@ -0,0 +1,28 @@
+// Package firmware provides the default firmware of the virtual machine.
+package firmware
+
+import (
+	_ "embed"
+	"fmt"
+	"sync"
+
+	"github.com/bartekpacia/toyvm/asm"
+)
+
+//go:embed firmware.nasm
+var source []byte
+
+var assemble = sync.OnceValues(func() ([]byte, error) {
+	return asm.Assemble(source)
+})
+
+// Default returns the default firmware image, assembled from the embedded
+// sources.
+func Default() ([]byte, error) {
+	image, err := assemble()
+	if err != nil {
+		return nil, fmt.Errorf("assemble default firmware: %w", err)
+	}
+
+	return image, nil
+}
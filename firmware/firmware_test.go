@@ -0,0 +1,113 @@
+package firmware_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/bartekpacia/toyvm/asm"
+	"github.com/bartekpacia/toyvm/firmware"
+	"github.com/bartekpacia/toyvm/vm"
+)
+
+func run(t *testing.T, image []byte, disk *vm.Disk) string {
+	t.Helper()
+
+	fw, err := firmware.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	machine := vm.NewVM()
+	stdout := bytes.NewBuffer(nil)
+	machine.Stdout = stdout
+	if disk != nil {
+		err = machine.AttachDevice(disk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = machine.Boot(fw, image)
+	if err != nil {
+		t.Fatalf("failed to boot: %v", err)
+	}
+
+	err = machine.Run()
+	if err != nil {
+		t.Fatalf("failed to run: %v", err)
+	}
+
+	return stdout.String()
+}
+
+func assemble(t *testing.T, src string) []byte {
+	t.Helper()
+
+	code, err := asm.Assemble([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return code
+}
+
+func TestDefault(t *testing.T) {
+	fw, err := firmware.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fw) > vm.FirmwareSize {
+		t.Errorf("firmware is %d bytes, maximum is %d", len(fw), vm.FirmwareSize)
+	}
+}
+
+func TestBootImage(t *testing.T) {
+	image := assemble(t, "vset r0, 'I'\nvoutb 0x20, r0\nvoff")
+
+	got := run(t, image, nil)
+	if got != "I" {
+		t.Errorf("got %q, want %q", got, "I")
+	}
+}
+
+func TestBootDisk(t *testing.T) {
+	boot := assemble(t, "vset r0, 'D'\nvoutb 0x20, r0\nvoff\ntimes 510-($-$$) db 0\ndb 0x55, 0xaa")
+	filename := filepath.Join(t.TempDir(), "disk.img")
+	err := vm.CreateDisk(filename, 2, boot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disk, err := vm.OpenDisk(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disk.Close()
+
+	// The disk takes precedence over the image.
+	image := assemble(t, "vset r0, 'I'\nvoutb 0x20, r0\nvoff")
+	got := run(t, image, disk)
+	if got != "D" {
+		t.Errorf("got %q, want %q", got, "D")
+	}
+}
+
+func TestNoBootableDevice(t *testing.T) {
+	got := run(t, nil, nil)
+	want := "firmware: no bootable disk or image\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDefaultFaultHandler(t *testing.T) {
+	image := assemble(t, "vxor r1, r1\nvdiv r0, r1\nvoff")
+
+	got := run(t, image, nil)
+	want := "firmware: unhandled fault 1\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,448 @@
+// Isagen generates everything that describes the instruction set from the
+// specification in isa/isa.json: the dispatch table of the virtual machine, the
+// tables of the assembler and the disassembler, the macros in examples/vm.inc
+// and the opcode tables in the README.
+//
+// It is run by go generate in the isa directory:
+//
+//	go generate ./isa
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Paths of the files, relative to the root of the repository.
+const (
+	specPath   = "isa/isa.json"
+	isaPath    = "isa/instructions_gen.go"
+	vmPath     = "vm/opcodes_gen.go"
+	incPath    = "examples/vm.inc"
+	readmePath = "README.md"
+)
+
+type spec struct {
+	Registers int     `json:"registers"`
+	PC        int     `json:"pc"`
+	SP        int     `json:"sp"`
+	Groups    []group `json:"groups"`
+}
+
+// group is a group of instructions, with a table in the README.
+type group struct {
+	ID           string        `json:"id"`
+	Title        string        `json:"title"`
+	Instructions []instruction `json:"instructions"`
+}
+
+type instruction struct {
+	Opcode      string   `json:"opcode"`
+	Mnemonic    string   `json:"mnemonic"`
+	Name        string   `json:"name"`     // in plain English
+	Operands    []string `json:"operands"` // name, or name:kind if the name does not tell the kind
+	Order       []int    `json:"order"`    // order of operands in the machine code, if different
+	Aliases     []alias  `json:"aliases"`
+	Description string   `json:"description"`
+
+	code  byte
+	kinds []string
+	group *group
+}
+
+type alias struct {
+	Mnemonic string `json:"mnemonic"`
+	Name     string `json:"name"`
+}
+
+// sizes are the sizes of the kinds of operands in the machine code.
+var sizes = map[string]int{"reg": 1, "imm8": 1, "imm16": 2, "imm32": 4, "rel16": 2}
+
+func main() {
+	root := flag.String("root", "..", "root of the repository")
+	flag.Parse()
+
+	files, err := generate(*root)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	for path, content := range files {
+		err := os.WriteFile(filepath.Join(*root, path), content, 0o644)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// generate returns the contents of the generated files by their paths.
+func generate(root string) (map[string][]byte, error) {
+	data, err := os.ReadFile(filepath.Join(root, specPath))
+	if err != nil {
+		return nil, err
+	}
+
+	var s spec
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", specPath, err)
+	}
+
+	instructions, err := s.check()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", specPath, err)
+	}
+
+	readme, err := os.ReadFile(filepath.Join(root, readmePath))
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{incPath: s.inc(instructions)}
+	files[isaPath], err = format.Source(s.isa(instructions))
+	if err != nil {
+		return nil, err
+	}
+	files[vmPath], err = format.Source(s.vm(instructions))
+	if err != nil {
+		return nil, err
+	}
+	files[readmePath], err = s.readme(readme)
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// check validates the specification and returns all instructions sorted by
+// opcode.
+func (s *spec) check() ([]*instruction, error) {
+	if s.PC < 0 || s.PC >= s.Registers || s.SP < 0 || s.SP >= s.Registers || s.PC == s.SP {
+		return nil, errors.New("invalid pc or sp register")
+	}
+
+	var instructions []*instruction
+	opcodes := make(map[byte]string)
+	mnemonics := make(map[string]bool)
+	for i := range s.Groups {
+		g := &s.Groups[i]
+		for j := range g.Instructions {
+			in := &g.Instructions[j]
+			in.group = g
+
+			code, err := strconv.ParseUint(in.Opcode, 0, 8)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid opcode %q", in.Mnemonic, in.Opcode)
+			}
+			in.code = byte(code)
+			if other, ok := opcodes[in.code]; ok {
+				return nil, fmt.Errorf("%s: opcode 0x%02x is also %s", in.Mnemonic, in.code, other)
+			}
+			opcodes[in.code] = in.Mnemonic
+
+			for _, mnemonic := range append([]string{in.Mnemonic}, in.aliases()...) {
+				if mnemonic == "" || mnemonic != strings.ToLower(mnemonic) || mnemonics[mnemonic] {
+					return nil, fmt.Errorf("%s: invalid or duplicate mnemonic %q", in.Mnemonic, mnemonic)
+				}
+				mnemonics[mnemonic] = true
+			}
+
+			in.kinds = make([]string, len(in.Operands))
+			for k, operand := range in.Operands {
+				name, kind, ok := strings.Cut(operand, ":")
+				if !ok {
+					kind = name
+					if strings.HasPrefix(name, "r") {
+						kind = "reg"
+					}
+				}
+				if _, ok := sizes[kind]; !ok {
+					return nil, fmt.Errorf("%s: unknown kind of operand %q", in.Mnemonic, operand)
+				}
+				in.kinds[k] = kind
+			}
+
+			if in.Order != nil {
+				sorted := slices.Sorted(slices.Values(in.Order))
+				for k := range sorted {
+					if len(sorted) != len(in.Operands) || sorted[k] != k {
+						return nil, fmt.Errorf("%s: invalid operand order %v", in.Mnemonic, in.Order)
+					}
+				}
+			}
+
+			instructions = append(instructions, in)
+		}
+	}
+
+	slices.SortFunc(instructions, func(a, b *instruction) int {
+		return int(a.code) - int(b.code)
+	})
+
+	return instructions, nil
+}
+
+func (in *instruction) aliases() []string {
+	var mnemonics []string
+	for _, a := range in.Aliases {
+		mnemonics = append(mnemonics, a.Mnemonic)
+	}
+	return mnemonics
+}
+
+// encoded returns the indices of the operands in the order of the machine
+// code.
+func (in *instruction) encoded() []int {
+	if in.Order != nil {
+		return in.Order
+	}
+
+	order := make([]int, len(in.Operands))
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// operandNames returns the names of the operands, as written in the README.
+func (in *instruction) operandNames() []string {
+	var names []string
+	for _, operand := range in.Operands {
+		name, _, _ := strings.Cut(operand, ":")
+		names = append(names, name)
+	}
+	return names
+}
+
+// registers returns the names of the registers and their numbers.
+func (s *spec) registers() ([]string, []int) {
+	var names []string
+	var numbers []int
+	for i := range s.Registers {
+		names = append(names, fmt.Sprintf("r%d", i))
+		numbers = append(numbers, i)
+	}
+	return append(names, "pc", "sp"), append(numbers, s.PC, s.SP)
+}
+
+const goHeader = "// Code generated by isagen from isa/isa.json. DO NOT EDIT.\n\n"
+
+// isa generates the tables of the assembler and the disassembler.
+func (s *spec) isa(instructions []*instruction) []byte {
+	var b bytes.Buffer
+	b.WriteString(goHeader)
+	b.WriteString("package isa\n\n")
+
+	b.WriteString("// Registers are the names of the registers and their numbers.\n")
+	b.WriteString("var Registers = map[string]int{\n")
+	names, numbers := s.registers()
+	for i, name := range names {
+		fmt.Fprintf(&b, "%q: %d,\n", name, numbers[i])
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("// Numbers of the registers with a special purpose.\n")
+	b.WriteString("const (\n")
+	fmt.Fprintf(&b, "PC = %d // program counter\n", s.PC)
+	fmt.Fprintf(&b, "SP = %d // stack pointer\n", s.SP)
+	b.WriteString(")\n\n")
+
+	b.WriteString("// instructions are the built-in instructions.\n")
+	b.WriteString("var instructions = map[string]Instruction{\n")
+	for _, in := range instructions {
+		var fields string
+		if len(in.kinds) > 0 {
+			var kinds []string
+			for _, kind := range in.kinds {
+				kinds = append(kinds, strings.ToUpper(kind[:1])+kind[1:])
+			}
+			fields += fmt.Sprintf(", Operands: []Operand{%s}", strings.Join(kinds, ", "))
+		}
+		if in.Order != nil {
+			var order []string
+			for _, i := range in.Order {
+				order = append(order, strconv.Itoa(i))
+			}
+			fields += fmt.Sprintf(", Order: []int{%s}", strings.Join(order, ", "))
+		}
+
+		fmt.Fprintf(&b, "%q: {Opcode: 0x%02x%s},\n", in.Mnemonic, in.code, fields)
+		for _, mnemonic := range in.aliases() {
+			fmt.Fprintf(&b, "%q: {Opcode: 0x%02x%s, Alias: true},\n", mnemonic, in.code, fields)
+		}
+	}
+	b.WriteString("}\n")
+
+	return b.Bytes()
+}
+
+// vm generates the dispatch table of the virtual machine. The handler of an
+// instruction is named after its mnemonic.
+func (s *spec) vm(instructions []*instruction) []byte {
+	var b bytes.Buffer
+	b.WriteString(goHeader)
+	b.WriteString("package vm\n\n")
+
+	b.WriteString("var opcodes = map[byte]opcode{\n")
+	var last *group
+	for _, in := range instructions {
+		if in.group != last {
+			fmt.Fprintf(&b, "// %s\n", strings.ToLower(in.group.Title))
+			last = in.group
+		}
+
+		length := "0"
+		if len(in.kinds) > 0 {
+			var lengths []string
+			for _, i := range in.encoded() {
+				lengths = append(lengths, strconv.Itoa(sizes[in.kinds[i]]))
+			}
+			length = strings.Join(lengths, " + ")
+		}
+
+		mnemonic := strings.ToUpper(in.Mnemonic)
+		fmt.Fprintf(&b, "0x%02X: {handler: %s, length: %s, mnemonic: %q},\n", in.code, mnemonic, length, mnemonic[1:])
+	}
+	b.WriteString("}\n")
+
+	return b.Bytes()
+}
+
+// inc generates the nasm macros of vm.inc.
+func (s *spec) inc(instructions []*instruction) []byte {
+	var b bytes.Buffer
+	b.WriteString("; Code generated by isagen from isa/isa.json. DO NOT EDIT.\n\n")
+	b.WriteString("[org 0x0]\n\n")
+
+	b.WriteString("; Registers.\n")
+	names, numbers := s.registers()
+	for i, name := range names {
+		fmt.Fprintf(&b, "%%define %s %d\n", name, numbers[i])
+	}
+
+	var last *group
+	for _, in := range instructions {
+		if in.group != last {
+			fmt.Fprintf(&b, "\n; %s.\n", in.group.Title)
+			last = in.group
+		}
+
+		fmt.Fprintf(&b, "\n%%macro %s %d\n", in.Mnemonic, len(in.Operands))
+		line := fmt.Sprintf("db 0x%02x", in.code)
+		bytesLine := true
+		for _, i := range in.encoded() {
+			arg := fmt.Sprintf("%%%d", i+1)
+			switch in.kinds[i] {
+			case "reg", "imm8":
+				if bytesLine {
+					line += ", " + arg
+					continue
+				}
+				b.WriteString(line + "\n")
+				line, bytesLine = "db "+arg, true
+				continue
+			case "imm16":
+				arg = "dw " + arg
+			case "rel16":
+				// The jump is relative to the address of the next instruction.
+				arg = fmt.Sprintf("dw (%s - ($ + 2))", arg)
+			case "imm32":
+				arg = "dd " + arg
+			}
+			b.WriteString(line + "\n")
+			line, bytesLine = arg, false
+		}
+		b.WriteString(line + "\n")
+		b.WriteString("%endmacro\n")
+
+		for _, mnemonic := range in.aliases() {
+			fmt.Fprintf(&b, "%%define %s %s\n", mnemonic, in.Mnemonic)
+		}
+	}
+
+	return b.Bytes()
+}
+
+// readme replaces the opcode tables of the README, each between the lines
+// <!-- begin isa ID --> and <!-- end isa -->.
+func (s *spec) readme(readme []byte) ([]byte, error) {
+	for _, g := range s.Groups {
+		begin := fmt.Appendf(nil, "<!-- begin isa %s -->\n", g.ID)
+		end := []byte("<!-- end isa -->\n")
+
+		start := bytes.Index(readme, begin)
+		if start < 0 {
+			return nil, fmt.Errorf("%s: no table for %s", readmePath, g.ID)
+		}
+		start += len(begin)
+		stop := bytes.Index(readme[start:], end)
+		if stop < 0 {
+			return nil, fmt.Errorf("%s: table for %s does not end", readmePath, g.ID)
+		}
+
+		readme = slices.Concat(readme[:start], []byte("\n"), g.table(), []byte("\n"), readme[start+stop:])
+	}
+
+	return readme, nil
+}
+
+// table generates the Markdown table of the group.
+func (g *group) table() []byte {
+	rows := [][]string{{"Opcode (hex)", "Mnemonic", "Mnemonic name in plain English", "Parameters", "Full description"}}
+	for _, in := range g.Instructions {
+		mnemonics := []string{strings.ToUpper(in.Mnemonic)}
+		names := []string{"**" + in.Name + "**"}
+		for _, a := range in.Aliases {
+			mnemonics = append(mnemonics, strings.ToUpper(a.Mnemonic))
+			names = append(names, "**"+a.Name+"**")
+		}
+
+		params := "none"
+		if len(in.Operands) > 0 {
+			params = strings.Join(in.operandNames(), ", ")
+		}
+
+		rows = append(rows, []string{
+			strings.ToUpper(strings.TrimPrefix(in.Opcode, "0x")),
+			strings.Join(mnemonics, "<br>"),
+			strings.Join(names, "<br>"),
+			params,
+			strings.ReplaceAll(in.Description, "|", `\|`),
+		})
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	var b bytes.Buffer
+	for r, row := range rows {
+		for i, cell := range row {
+			fmt.Fprintf(&b, "| %-*s ", widths[i], cell)
+		}
+		b.WriteString("|\n")
+
+		if r == 0 {
+			for _, width := range widths {
+				fmt.Fprintf(&b, "|:%s", strings.Repeat("-", width+1))
+			}
+			b.WriteString("|\n")
+		}
+	}
+
+	return b.Bytes()
+}
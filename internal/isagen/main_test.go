@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeneratedFilesUpToDate(t *testing.T) {
+	const root = "../.."
+
+	files, err := generate(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range files {
+		got, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is out of date, run go generate ./isa", path)
+		}
+	}
+}
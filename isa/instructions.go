@@ -0,0 +1,60 @@
+package isa
+
+// Operand is the kind of an instruction operand.
+type Operand int
+
+const (
+	Reg   Operand = iota // register number
+	Imm8                 // 8-bit immediate
+	Imm16                // 16-bit immediate
+	Imm32                // 32-bit immediate
+	Rel16                // address, encoded relative to the next instruction
+)
+
+// Size returns the number of bytes the operand takes in the machine code.
+func (o Operand) Size() int {
+	switch o {
+	case Reg, Imm8:
+		return 1
+	case Imm16, Rel16:
+		return 2
+	default:
+		return 4
+	}
+}
+
+// Fits reports whether the value can be encoded in the operand.
+func (o Operand) Fits(value int) bool {
+	switch o {
+	case Reg:
+		return value >= 0 && value <= 15
+	case Imm8:
+		return value >= -0x80 && value <= 0xff
+	case Imm16, Rel16:
+		return value >= -0x8000 && value <= 0xffff
+	default:
+		return value >= -0x80000000 && value <= 0xffffffff
+	}
+}
+
+// valid reports whether the operand is one of the kinds above.
+func (o Operand) valid() bool {
+	return o >= Reg && o <= Rel16
+}
+
+// Instruction describes how an instruction is written and encoded.
+type Instruction struct {
+	Opcode   byte
+	Operands []Operand // in the order they are written in the source
+	Order    []int     // order of operands in the machine code, if different
+	Alias    bool      // another mnemonic of the same instruction
+}
+
+// Size returns the number of bytes the instruction takes in the machine code.
+func (in Instruction) Size() int {
+	size := 1
+	for _, kind := range in.Operands {
+		size += kind.Size()
+	}
+	return size
+}
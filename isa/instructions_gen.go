@@ -0,0 +1,113 @@
+// Code generated by isagen from isa/isa.json. DO NOT EDIT.
+
+package isa
+
+// Registers are the names of the registers and their numbers.
+var Registers = map[string]int{
+	"r0":  0,
+	"r1":  1,
+	"r2":  2,
+	"r3":  3,
+	"r4":  4,
+	"r5":  5,
+	"r6":  6,
+	"r7":  7,
+	"r8":  8,
+	"r9":  9,
+	"r10": 10,
+	"r11": 11,
+	"r12": 12,
+	"r13": 13,
+	"r14": 14,
+	"r15": 15,
+	"pc":  15,
+	"sp":  14,
+}
+
+// Numbers of the registers with a special purpose.
+const (
+	PC = 15 // program counter
+	SP = 14 // stack pointer
+)
+
+// instructions are the built-in instructions.
+var instructions = map[string]Instruction{
+	"vmov":   {Opcode: 0x00, Operands: []Operand{Reg, Reg}},
+	"vset":   {Opcode: 0x01, Operands: []Operand{Reg, Imm32}},
+	"vld":    {Opcode: 0x02, Operands: []Operand{Reg, Reg}},
+	"vst":    {Opcode: 0x03, Operands: []Operand{Reg, Reg}},
+	"vldb":   {Opcode: 0x04, Operands: []Operand{Reg, Reg}},
+	"vstb":   {Opcode: 0x05, Operands: []Operand{Reg, Reg}},
+	"vldw":   {Opcode: 0x06, Operands: []Operand{Reg, Reg}},
+	"vldsw":  {Opcode: 0x07, Operands: []Operand{Reg, Reg}},
+	"vstw":   {Opcode: 0x08, Operands: []Operand{Reg, Reg}},
+	"vldsp":  {Opcode: 0x09, Operands: []Operand{Reg, Imm16}},
+	"vstsp":  {Opcode: 0x0a, Operands: []Operand{Imm16, Reg}, Order: []int{1, 0}},
+	"vadd":   {Opcode: 0x10, Operands: []Operand{Reg, Reg}},
+	"vsub":   {Opcode: 0x11, Operands: []Operand{Reg, Reg}},
+	"vmul":   {Opcode: 0x12, Operands: []Operand{Reg, Reg}},
+	"vdiv":   {Opcode: 0x13, Operands: []Operand{Reg, Reg}},
+	"vmod":   {Opcode: 0x14, Operands: []Operand{Reg, Reg}},
+	"vor":    {Opcode: 0x15, Operands: []Operand{Reg, Reg}},
+	"vand":   {Opcode: 0x16, Operands: []Operand{Reg, Reg}},
+	"vxor":   {Opcode: 0x17, Operands: []Operand{Reg, Reg}},
+	"vnot":   {Opcode: 0x18, Operands: []Operand{Reg}},
+	"vshl":   {Opcode: 0x19, Operands: []Operand{Reg, Reg}},
+	"vshr":   {Opcode: 0x1a, Operands: []Operand{Reg, Reg}},
+	"vcmp":   {Opcode: 0x20, Operands: []Operand{Reg, Reg}},
+	"vjz":    {Opcode: 0x21, Operands: []Operand{Rel16}},
+	"vje":    {Opcode: 0x21, Operands: []Operand{Rel16}, Alias: true},
+	"vjnz":   {Opcode: 0x22, Operands: []Operand{Rel16}},
+	"vjne":   {Opcode: 0x22, Operands: []Operand{Rel16}, Alias: true},
+	"vjc":    {Opcode: 0x23, Operands: []Operand{Rel16}},
+	"vjb":    {Opcode: 0x23, Operands: []Operand{Rel16}, Alias: true},
+	"vjnc":   {Opcode: 0x24, Operands: []Operand{Rel16}},
+	"vjae":   {Opcode: 0x24, Operands: []Operand{Rel16}, Alias: true},
+	"vjbe":   {Opcode: 0x25, Operands: []Operand{Rel16}},
+	"vja":    {Opcode: 0x26, Operands: []Operand{Rel16}},
+	"vjl":    {Opcode: 0x27, Operands: []Operand{Rel16}},
+	"vjge":   {Opcode: 0x28, Operands: []Operand{Rel16}},
+	"vjle":   {Opcode: 0x29, Operands: []Operand{Rel16}},
+	"vjg":    {Opcode: 0x2a, Operands: []Operand{Rel16}},
+	"vpush":  {Opcode: 0x30, Operands: []Operand{Reg}},
+	"vpop":   {Opcode: 0x31, Operands: []Operand{Reg}},
+	"vjmp":   {Opcode: 0x40, Operands: []Operand{Rel16}},
+	"vjmpr":  {Opcode: 0x41, Operands: []Operand{Reg}},
+	"vcall":  {Opcode: 0x42, Operands: []Operand{Rel16}},
+	"vcallr": {Opcode: 0x43, Operands: []Operand{Reg}},
+	"vret":   {Opcode: 0x44},
+	"vidiv":  {Opcode: 0x50, Operands: []Operand{Reg, Reg}},
+	"vimod":  {Opcode: 0x51, Operands: []Operand{Reg, Reg}},
+	"vsar":   {Opcode: 0x52, Operands: []Operand{Reg, Reg}},
+	"vrol":   {Opcode: 0x53, Operands: []Operand{Reg, Reg}},
+	"vror":   {Opcode: 0x54, Operands: []Operand{Reg, Reg}},
+	"vadc":   {Opcode: 0x55, Operands: []Operand{Reg, Reg}},
+	"vsbb":   {Opcode: 0x56, Operands: []Operand{Reg, Reg}},
+	"vmull":  {Opcode: 0x57, Operands: []Operand{Reg, Reg}},
+	"vcas":   {Opcode: 0x60, Operands: []Operand{Reg, Reg, Reg}},
+	"vxchg":  {Opcode: 0x61, Operands: []Operand{Reg, Reg}},
+	"vfadd":  {Opcode: 0x70, Operands: []Operand{Reg, Reg}},
+	"vfsub":  {Opcode: 0x71, Operands: []Operand{Reg, Reg}},
+	"vfmul":  {Opcode: 0x72, Operands: []Operand{Reg, Reg}},
+	"vfdiv":  {Opcode: 0x73, Operands: []Operand{Reg, Reg}},
+	"vfcmp":  {Opcode: 0x74, Operands: []Operand{Reg, Reg}},
+	"vitof":  {Opcode: 0x75, Operands: []Operand{Reg}},
+	"vftoi":  {Opcode: 0x76, Operands: []Operand{Reg}},
+	"vldo":   {Opcode: 0x80, Operands: []Operand{Reg, Reg, Imm16}},
+	"vsto":   {Opcode: 0x81, Operands: []Operand{Reg, Imm16, Reg}, Order: []int{0, 2, 1}},
+	"vldbo":  {Opcode: 0x82, Operands: []Operand{Reg, Reg, Imm16}},
+	"vstbo":  {Opcode: 0x83, Operands: []Operand{Reg, Imm16, Reg}, Order: []int{0, 2, 1}},
+	"vldwo":  {Opcode: 0x84, Operands: []Operand{Reg, Reg, Imm16}},
+	"vldswo": {Opcode: 0x85, Operands: []Operand{Reg, Reg, Imm16}},
+	"vstwo":  {Opcode: 0x86, Operands: []Operand{Reg, Imm16, Reg}, Order: []int{0, 2, 1}},
+	"vcrl":   {Opcode: 0xf0, Operands: []Operand{Imm16, Reg}, Order: []int{1, 0}},
+	"vcrs":   {Opcode: 0xf1, Operands: []Operand{Imm16, Reg}, Order: []int{1, 0}},
+	"voutb":  {Opcode: 0xf2, Operands: []Operand{Imm8, Reg}, Order: []int{1, 0}},
+	"vinb":   {Opcode: 0xf3, Operands: []Operand{Imm8, Reg}, Order: []int{1, 0}},
+	"viret":  {Opcode: 0xf4},
+	"vint":   {Opcode: 0xf5, Operands: []Operand{Imm8}},
+	"vhlt":   {Opcode: 0xf6},
+	"vhcall": {Opcode: 0xf7, Operands: []Operand{Imm16}},
+	"vcrsh":  {Opcode: 0xfe},
+	"voff":   {Opcode: 0xff},
+}
@@ -0,0 +1,115 @@
+// Package isa describes the instruction set of the virtual machine: how each
+// instruction is written in assembly and encoded in machine code. It is shared
+// by the virtual machine, the assembler and the disassembler, so that
+// instructions registered by extensions are known to all of them.
+package isa
+
+//go:generate go run ../internal/isagen
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrOpcodeInUse   = errors.New("opcode already in use")
+	ErrMnemonicInUse = errors.New("mnemonic already in use")
+)
+
+var (
+	mu        sync.RWMutex
+	mnemonics = make(map[byte]string) // by opcode, without aliases
+)
+
+func init() {
+	for mnemonic, in := range instructions {
+		if !in.Alias {
+			mnemonics[in.Opcode] = mnemonic
+		}
+	}
+}
+
+// Lookup returns the instruction with the mnemonic, which must be lowercase.
+func Lookup(mnemonic string) (Instruction, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	in, ok := instructions[mnemonic]
+	return in, ok
+}
+
+// Mnemonics returns the mnemonics of all instructions, including aliases, in
+// alphabetical order.
+func Mnemonics() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return slices.Sorted(maps.Keys(instructions))
+}
+
+// Decode returns the instruction with the opcode and its mnemonic. Aliases
+// are never returned.
+func Decode(opcode byte) (string, Instruction, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	mnemonic, ok := mnemonics[opcode]
+	return mnemonic, instructions[mnemonic], ok
+}
+
+// Register adds an instruction with the mnemonic to the instruction set. It
+// fails if the opcode or the mnemonic is already in use. Mnemonics are case
+// insensitive, like in the assembler.
+func Register(mnemonic string, in Instruction) error {
+	mnemonic = strings.ToLower(mnemonic)
+	if mnemonic == "" || strings.ContainsAny(mnemonic, " \t,;") {
+		return fmt.Errorf("invalid mnemonic %q", mnemonic)
+	}
+	if in.Alias {
+		return fmt.Errorf("%s: aliases cannot be registered", mnemonic)
+	}
+	for _, kind := range in.Operands {
+		if !kind.valid() {
+			return fmt.Errorf("%s: invalid operand kind %d", mnemonic, kind)
+		}
+	}
+	if in.Order != nil && !isPermutation(in.Order, len(in.Operands)) {
+		return fmt.Errorf("%s: invalid operand order %v", mnemonic, in.Order)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if other, ok := mnemonics[in.Opcode]; ok {
+		return fmt.Errorf("%w: 0x%02x is %s", ErrOpcodeInUse, in.Opcode, other)
+	}
+	if _, ok := instructions[mnemonic]; ok {
+		return fmt.Errorf("%w: %s", ErrMnemonicInUse, mnemonic)
+	}
+
+	instructions[mnemonic] = in
+	mnemonics[in.Opcode] = mnemonic
+	return nil
+}
+
+// isPermutation reports whether order holds each of the numbers from 0 to n-1
+// once.
+func isPermutation(order []int, n int) bool {
+	if len(order) != n {
+		return false
+	}
+
+	seen := make([]bool, n)
+	for _, i := range order {
+		if i < 0 || i >= n || seen[i] {
+			return false
+		}
+		seen[i] = true
+	}
+
+	return true
+}
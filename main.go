@@ -1,35 +1,409 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/bartekpacia/toyvm/asm"
+	"github.com/bartekpacia/toyvm/firmware"
 	"github.com/bartekpacia/toyvm/vm"
 )
 
 func main() {
 	log.SetFlags(0)
 
-	if len(os.Args) < 2 {
-		log.Fatalln("usage: vm <source file>")
+	if len(os.Args) > 1 && os.Args[1] == "mkdisk" {
+		mkdisk(os.Args[2:])
+		return
 	}
 
-	debug := false
-	for _, arg := range os.Args {
-		if arg == "-debug" || arg == "--debug" {
-			debug = true
+	if len(os.Args) > 1 && os.Args[1] == "disasm" {
+		disasm(os.Args[2:])
+		return
+	}
+
+	err := run()
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// run parses the flags, then sets up and runs the virtual machine.
+func run() (err error) {
+	debug := flag.Bool("debug", false, "print debug information")
+	semihostDir := flag.String("semihost", "", "give the guest access to files in `dir`")
+	diskImage := flag.String("disk", "", "attach the disk `image`")
+	useFirmware := flag.Bool("firmware", false, "boot from the firmware instead of executing the source file at address 0")
+	firmwareFile := flag.String("firmware-image", "", "boot from the firmware in `file` instead of the default one (implies -firmware)")
+	display := flag.Bool("display", false, "render the text display to the terminal")
+	displaySnapshots := flag.String("display-snapshots", "", "save the text display to files in `dir` instead of rendering it")
+	framebufferDir := flag.String("framebuffer", "", "attach the framebuffer and save captured frames in `dir`")
+	framebufferFormat := flag.String("framebuffer-format", "png", "`format` of captured frames (png or ppm)")
+	framebufferEvery := flag.Uint64("framebuffer-every", 0, "capture a frame every `n` cycles")
+	audioFile := flag.String("audio", "", "attach the sound device and record it to the WAV `file`")
+	rng := flag.Bool("rng", false, "attach the random number generator, seeded from the host clock")
+	rngSeed := flag.String("rng-seed", "", "attach the random number generator with the `seed`")
+	rtc := flag.Bool("rtc", false, "attach the real-time clock with wall-clock time")
+	rtcStart := flag.String("rtc-virtual", "", "attach the real-time clock with virtual time starting at `time` (RFC 3339)")
+	watchdog := flag.Bool("watchdog", false, "attach the watchdog, with the timeout in cycles")
+	watchdogWallClock := flag.Bool("watchdog-wall-clock", false, "attach the watchdog, with the timeout in milliseconds")
+	dma := flag.Bool("dma", false, "attach the DMA controller")
+	var uarts []string
+	flag.Func("uart", "attach a UART whose host side is `spec`: pty, unix:<path> or tcp:<address> (127.0.0.1 if the host is omitted), optionally followed by @<port> (can be repeated)", func(spec string) error {
+		uarts = append(uarts, spec)
+		return nil
+	})
+	nic := flag.Bool("nic", false, "attach a network interface")
+	pcapFile := flag.String("pcap", "", "attach a network interface and log its frames to the pcap `file`")
+	cores := flag.Int("cores", 1, "run a machine with `n` cores")
+	parallel := flag.Bool("parallel", false, "run the cores in parallel instead of taking turns")
+	stats := flag.Bool("stats", false, "print statistics when the machine stops")
+	flag.Parse()
+
+	if flag.NArg() < 1 && (*diskImage == "" || !*useFirmware && *firmwareFile == "") {
+		return errors.New("usage: vm [flags] <source file> [flags]\n       vm [flags] -firmware -disk <image>\n       vm mkdisk [flags] <image> <sectors>\n       vm disasm [flags] <file>")
+	}
+
+	// Flags are accepted after the source file as well.
+	filename := flag.Arg(0)
+	if flag.NArg() > 0 {
+		_ = flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	if *firmwareFile != "" {
+		*useFirmware = true
+	}
+
+	multi, err := vm.NewMachine(*cores)
+	if err != nil {
+		return err
+	}
+	if *parallel {
+		multi.Scheduler = vm.Parallel
+	}
+
+	// Memory and devices are set up through core 0.
+	machine := multi.Core(0)
+	if *useFirmware {
+		err := boot(machine, *firmwareFile, filename)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := machine.LoadMemoryFromFile(0, filename)
+		if err != nil {
+			return fmt.Errorf("failed to load memory from file: %w", err)
+		}
+	}
+
+	if *semihostDir != "" {
+		semihost, err := vm.NewSemihost(*semihostDir)
+		if err != nil {
+			return fmt.Errorf("failed to create semihosting device: %w", err)
+		}
+		defer semihost.Close()
+
+		err = machine.AttachDevice(semihost)
+		if err != nil {
+			return fmt.Errorf("failed to attach semihosting device: %w", err)
+		}
+	}
+
+	if *diskImage != "" {
+		disk, err := vm.OpenDisk(*diskImage)
+		if err != nil {
+			return fmt.Errorf("failed to open disk: %w", err)
+		}
+		defer disk.Close()
+
+		err = machine.AttachDevice(disk)
+		if err != nil {
+			return fmt.Errorf("failed to attach disk: %w", err)
+		}
+	}
+
+	if *display || *displaySnapshots != "" {
+		var textDisplay *vm.TextDisplay
+		if *displaySnapshots != "" {
+			textDisplay = vm.NewHeadlessTextDisplay(*displaySnapshots)
+		} else {
+			textDisplay = vm.NewTextDisplay(os.Stdout)
+		}
+
+		err := machine.AttachDevice(textDisplay)
+		if err != nil {
+			return fmt.Errorf("failed to attach text display: %w", err)
 		}
 	}
 
-	machine := vm.NewVM()
-	err := machine.LoadMemoryFromFile(0, os.Args[1])
+	if *framebufferDir != "" {
+		framebuffer := vm.NewFramebuffer(*framebufferDir)
+		framebuffer.Format = vm.ImageFormat(*framebufferFormat)
+		framebuffer.CaptureEvery = *framebufferEvery
+		if framebuffer.Format != vm.FormatPNG && framebuffer.Format != vm.FormatPPM {
+			return fmt.Errorf("invalid framebuffer format: %s", *framebufferFormat)
+		}
+
+		err := machine.AttachDevice(framebuffer)
+		if err != nil {
+			return fmt.Errorf("failed to attach framebuffer: %w", err)
+		}
+	}
+
+	if *audioFile != "" {
+		var audio *vm.Audio
+		audio, err = vm.NewAudio(*audioFile)
+		if err != nil {
+			return fmt.Errorf("failed to create sound device: %w", err)
+		}
+		// Closing writes the final sizes to the header of the WAV file.
+		defer func() {
+			closeErr := audio.Close()
+			if closeErr != nil && err == nil {
+				err = fmt.Errorf("failed to close sound device: %w", closeErr)
+			}
+		}()
+
+		err = machine.AttachDevice(audio)
+		if err != nil {
+			return fmt.Errorf("failed to attach sound device: %w", err)
+		}
+	}
+
+	if *rng || *rngSeed != "" {
+		seed := uint64(time.Now().UnixNano())
+		if *rngSeed != "" {
+			var err error
+			seed, err = strconv.ParseUint(*rngSeed, 0, 64)
+			if err != nil {
+				return fmt.Errorf("invalid random number generator seed: %w", err)
+			}
+		}
+
+		err := machine.AttachDevice(vm.NewRNG(seed))
+		if err != nil {
+			return fmt.Errorf("failed to attach random number generator: %w", err)
+		}
+	}
+
+	if *rtc || *rtcStart != "" {
+		clock := vm.NewRTC()
+		if *rtcStart != "" {
+			start, err := time.Parse(time.RFC3339, *rtcStart)
+			if err != nil {
+				return fmt.Errorf("invalid real-time clock start: %w", err)
+			}
+
+			clock = vm.NewVirtualRTC(start)
+		}
+
+		err := machine.AttachDevice(clock)
+		if err != nil {
+			return fmt.Errorf("failed to attach real-time clock: %w", err)
+		}
+	}
+
+	if *watchdog || *watchdogWallClock {
+		wd := vm.NewWatchdog()
+		if *watchdogWallClock {
+			wd = vm.NewWallClockWatchdog()
+		}
+
+		err := machine.AttachDevice(wd)
+		if err != nil {
+			return fmt.Errorf("failed to attach watchdog: %w", err)
+		}
+	}
+
+	if *dma {
+		err := machine.AttachDevice(vm.NewDMA())
+		if err != nil {
+			return fmt.Errorf("failed to attach DMA controller: %w", err)
+		}
+	}
+
+	for i, spec := range uarts {
+		uart, err := openUART(spec, byte(vm.PortUART+i*vm.UARTPorts))
+		if err != nil {
+			return fmt.Errorf("failed to open UART %s: %w", spec, err)
+		}
+		defer uart.Close()
+
+		err = machine.AttachDevice(uart)
+		if err != nil {
+			return fmt.Errorf("failed to attach UART %s: %w", spec, err)
+		}
+	}
+
+	if *nic || *pcapFile != "" {
+		sw := vm.NewSwitch()
+		if *pcapFile != "" {
+			capture, err := os.Create(*pcapFile)
+			if err != nil {
+				return fmt.Errorf("failed to create pcap file: %w", err)
+			}
+			defer capture.Close()
+
+			err = sw.Capture(capture)
+			if err != nil {
+				return err
+			}
+		}
+
+		iface, err := vm.NewNIC(net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01})
+		if err != nil {
+			return err
+		}
+		sw.Connect(iface)
+		err = machine.AttachDevice(iface)
+		if err != nil {
+			return fmt.Errorf("failed to attach network interface: %w", err)
+		}
+	}
+
+	machine.SetDebug(*debug)
+	if *cores > 1 {
+		err = multi.Run()
+	} else {
+		err = machine.Run()
+	}
+	if *stats {
+		for i := range multi.Cores() {
+			s := multi.Core(i).Stats()
+			log.Printf("core %d: %d instructions, %d cycles, %d cycles halted (%v)", i, s.Instructions, s.Cycles, s.HaltedCycles, s.HaltedTime)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error while running virtual machine: %w", err)
+	}
+
+	return nil
+}
+
+// mkdisk creates a disk image.
+func mkdisk(args []string) {
+	flags := flag.NewFlagSet("mkdisk", flag.ExitOnError)
+	bootFile := flags.String("boot", "", "write the contents of `file` at the beginning of the image")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		log.Fatalln("usage: vm mkdisk [flags] <image> <sectors>")
+	}
+
+	sectors, err := strconv.Atoi(flags.Arg(1))
+	if err != nil || sectors <= 0 {
+		log.Fatalln("invalid number of sectors:", flags.Arg(1))
+	}
+
+	var boot []byte
+	if *bootFile != "" {
+		boot, err = os.ReadFile(*bootFile)
+		if err != nil {
+			log.Fatalln("failed to read boot file:", err)
+		}
+	}
+
+	err = vm.CreateDisk(flags.Arg(0), sectors, boot)
+	if err != nil {
+		log.Fatalln("failed to create disk:", err)
+	}
+
+	fmt.Printf("created %s (%d sectors, %d bytes)\n", flags.Arg(0), sectors, sectors*vm.SectorSize)
+}
+
+// disasm prints the disassembly of a file with machine code.
+func disasm(args []string) {
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	org := flags.Uint("org", 0, "disassemble as if the code was loaded at `address`")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatalln("usage: vm disasm [flags] <file>")
+	}
+
+	code, err := os.ReadFile(flags.Arg(0))
 	if err != nil {
-		log.Fatalln("failed to load memory from file:", err)
+		log.Fatalln("failed to read file:", err)
+	}
+
+	for _, line := range asm.Disassemble(code, int(*org)) {
+		fmt.Println(line)
+	}
+}
+
+// openUART opens a UART as described by spec. Unless spec ends with @<port>,
+// the UART is at the base port.
+func openUART(spec string, base byte) (*vm.UART, error) {
+	if i := strings.LastIndex(spec, "@"); i != -1 {
+		port, err := strconv.ParseUint(spec[i+1:], 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port: %w", err)
+		}
+
+		spec, base = spec[:i], byte(port)
+	}
+
+	if spec == "pty" {
+		uart, name, err := vm.OpenPTYUART(base)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("UART at port %#02x: %s", base, name)
+		return uart, nil
+	}
+
+	network, address, ok := strings.Cut(spec, ":")
+	if !ok || (network != "unix" && network != "tcp") {
+		return nil, errors.New("host side must be pty, unix:<path> or tcp:<address>")
 	}
 
-	machine.SetDebug(debug)
-	err = machine.Run()
+	// The guest console is not exposed to the network unless asked for.
+	if host, port, err := net.SplitHostPort(address); network == "tcp" && err == nil && host == "" {
+		address = net.JoinHostPort("127.0.0.1", port)
+	}
+
+	uart, err := vm.ListenUART(base, network, address)
 	if err != nil {
-		log.Fatalln("error while running virtual machine:", err)
+		return nil, err
+	}
+
+	log.Printf("UART at port %#02x: %s %s", base, network, uart.Addr())
+	return uart, nil
+}
+
+// boot prepares the machine to boot from the firmware.
+func boot(machine *vm.VM, firmwareFile, filename string) error {
+	var image []byte
+	if filename != "" {
+		var err error
+		image, err = os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to read source file: %w", err)
+		}
 	}
+
+	var fw []byte
+	var err error
+	if firmwareFile != "" {
+		fw, err = os.ReadFile(firmwareFile)
+	} else {
+		fw, err = firmware.Default()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load firmware: %w", err)
+	}
+
+	err = machine.Boot(fw, image)
+	if err != nil {
+		return fmt.Errorf("failed to boot: %w", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,178 @@
+package vm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// AudioSampleRate is the sample rate of the recorded sound, in Hz. A sample is
+// taken every ClockRate/AudioSampleRate cycles.
+const AudioSampleRate = 8000
+
+// Sound device ports. The square wave frequency is in Hz, written low byte
+// first. PCM samples are unsigned 8-bit; the last written sample is held until
+// the next one.
+const (
+	PortAudioFreqLow  = 0xb0
+	PortAudioFreqHigh = 0xb1
+	PortAudioControl  = 0xb2
+	PortAudioSample   = 0xb3
+	PortAudioVolume   = 0xb4
+)
+
+// Bits of the sound device control port. When both are set, the square wave
+// and the PCM sample are mixed.
+const (
+	AudioSquare = 1 << 0 // square wave generator on
+	AudioPCM    = 1 << 1 // PCM samples on
+)
+
+// Audio is a sound device with a square wave generator and a PCM sample port.
+// Its output is recorded to an 8-bit mono WAV file.
+type Audio struct {
+	file    *os.File
+	w       *bufio.Writer
+	samples uint32
+	err     error // first error while recording
+
+	freq    uint16
+	control byte
+	sample  byte
+	volume  byte
+	phase   int // of the square wave, in units of 1/AudioSampleRate of a period
+}
+
+// NewAudio creates a sound device that records to the WAV file filename.
+// The file is complete only after Close is called.
+func NewAudio(filename string) (*Audio, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("create audio file: %w", err)
+	}
+
+	a := &Audio{file: file, w: bufio.NewWriter(file), sample: 0x80, volume: 0xff}
+	err = a.writeHeader()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *Audio) Ports() []byte {
+	return []byte{PortAudioFreqLow, PortAudioFreqHigh, PortAudioControl, PortAudioSample, PortAudioVolume}
+}
+
+func (a *Audio) In(vm *VM, port byte) byte {
+	switch port {
+	case PortAudioFreqLow:
+		return byte(a.freq)
+	case PortAudioFreqHigh:
+		return byte(a.freq >> 8)
+	case PortAudioControl:
+		return a.control
+	case PortAudioSample:
+		return a.sample
+	case PortAudioVolume:
+		return a.volume
+	default:
+		return 0
+	}
+}
+
+func (a *Audio) Out(vm *VM, port byte, value byte) {
+	switch port {
+	case PortAudioFreqLow:
+		a.freq = a.freq&0xff00 | uint16(value)
+	case PortAudioFreqHigh:
+		a.freq = a.freq&0x00ff | uint16(value)<<8
+	case PortAudioControl:
+		a.control = value
+	case PortAudioSample:
+		a.sample = value
+	case PortAudioVolume:
+		a.volume = value
+	}
+}
+
+func (a *Audio) Tick(vm *VM) {
+	if vm.cycles%(ClockRate/AudioSampleRate) != 0 || a.err != nil {
+		return
+	}
+
+	a.err = a.w.WriteByte(a.next())
+	a.samples++
+}
+
+// next returns the next sample and advances the square wave.
+func (a *Audio) next() byte {
+	level := 0
+	if a.control&AudioSquare != 0 && a.freq != 0 {
+		if a.phase < AudioSampleRate/2 {
+			level += 127
+		} else {
+			level -= 127
+		}
+
+		a.phase = (a.phase + int(a.freq)) % AudioSampleRate
+	}
+
+	if a.control&AudioPCM != 0 {
+		level += int(a.sample) - 0x80
+	}
+
+	level = level * int(a.volume) / 0xff
+	return byte(0x80 + min(max(level, -0x80), 0x7f))
+}
+
+// Close finishes the WAV file.
+func (a *Audio) Close() error {
+	err := a.err
+	if err == nil {
+		err = a.w.Flush()
+	}
+	if err == nil {
+		_, err = a.file.Seek(0, 0)
+	}
+	if err == nil {
+		err = a.writeHeader()
+	}
+
+	closeErr := a.file.Close()
+	if err != nil {
+		return fmt.Errorf("write audio file: %w", err)
+	}
+
+	return closeErr
+}
+
+// writeHeader writes the WAV header for the samples recorded so far.
+func (a *Audio) writeHeader() error {
+	header := []any{
+		[4]byte{'R', 'I', 'F', 'F'},
+		uint32(36 + a.samples),
+		[4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16),              // size of the format chunk
+		uint16(1),               // PCM
+		uint16(1),               // channels
+		uint32(AudioSampleRate), // sample rate
+		uint32(AudioSampleRate), // byte rate
+		uint16(1),               // block align
+		uint16(8),               // bits per sample
+		[4]byte{'d', 'a', 't', 'a'},
+		a.samples,
+	}
+
+	for _, field := range header {
+		err := binary.Write(a.w, binary.LittleEndian, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	return a.w.Flush()
+}
@@ -0,0 +1,77 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAudioSquareWave(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "out.wav")
+	audio, err := NewAudio(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm := NewVM()
+	_ = vm.AttachDevice(audio)
+	audio.Out(vm, PortAudioFreqLow, 1000&0xff)
+	audio.Out(vm, PortAudioFreqHigh, 1000>>8)
+	audio.Out(vm, PortAudioControl, AudioSquare)
+	for range 16 * ClockRate / AudioSampleRate {
+		vm.cycles++
+		audio.Tick(vm)
+	}
+
+	err = audio.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != 44+16 {
+		t.Fatalf("got %d bytes, want %d", len(data), 44+16)
+	}
+	if !bytes.HasPrefix(data, []byte("RIFF")) || string(data[8:16]) != "WAVEfmt " {
+		t.Errorf("got header %q, want a WAV header", data[:16])
+	}
+	if rate := binary.LittleEndian.Uint32(data[24:]); rate != AudioSampleRate {
+		t.Errorf("got sample rate %d, want %d", rate, AudioSampleRate)
+	}
+	if size := binary.LittleEndian.Uint32(data[40:]); size != 16 {
+		t.Errorf("got data size %d, want 16", size)
+	}
+
+	// At 1000 Hz, a period is 8 samples long.
+	period := []byte{0xff, 0xff, 0xff, 0xff, 0x01, 0x01, 0x01, 0x01}
+	want := append(period, period...)
+	if got := data[44:]; !bytes.Equal(got, want) {
+		t.Errorf("got samples %x, want %x", got, want)
+	}
+}
+
+func TestAudioPCM(t *testing.T) {
+	audio, err := NewAudio(filepath.Join(t.TempDir(), "out.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audio.Close()
+
+	vm := NewVM()
+	audio.Out(vm, PortAudioControl, AudioPCM)
+	audio.Out(vm, PortAudioSample, 0xc0)
+	if got := audio.next(); got != 0xc0 {
+		t.Errorf("got sample %#x, want 0xc0", got)
+	}
+
+	audio.Out(vm, PortAudioVolume, 0x80)
+	if got := audio.next(); got != 0xa0 {
+		t.Errorf("got sample %#x at half volume, want 0xa0", got)
+	}
+}
@@ -0,0 +1,47 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Memory map of the boot model.
+const (
+	BootAddr     = 0x0000 // where the boot sector or the boot image is loaded
+	ScratchAddr  = 0xde00 // buffer the firmware uses while booting
+	FirmwareAddr = 0xe000 // where the firmware is mapped read-only
+	FirmwareSize = 0x1000
+)
+
+var ErrImageTooLarge = errors.New("image too large")
+
+// Boot prepares the machine to start from the firmware instead of address 0.
+//
+// The firmware is mapped read-only at FirmwareAddr. If image is not nil, it
+// is loaded at BootAddr. It must end below ScratchAddr, which the firmware
+// overwrites. The firmware starts with the size of the image in R0,
+// so that it can fall back to it if there is no bootable disk.
+func (vm *VM) Boot(firmware []byte, image []byte) error {
+	if len(firmware) > FirmwareSize {
+		return fmt.Errorf("%w: firmware is %d bytes, maximum is %d", ErrImageTooLarge, len(firmware), FirmwareSize)
+	}
+
+	if BootAddr+len(image) > ScratchAddr {
+		return fmt.Errorf("%w: boot image is %d bytes, maximum is %d", ErrImageTooLarge, len(image), ScratchAddr-BootAddr)
+	}
+
+	err := vm.memory.StoreMany(BootAddr, image)
+	if err != nil {
+		return fmt.Errorf("store boot image: %w", err)
+	}
+
+	err = vm.memory.StoreMany(FirmwareAddr, firmware)
+	if err != nil {
+		return fmt.Errorf("store firmware: %w", err)
+	}
+
+	vm.memory.Protect(FirmwareAddr, FirmwareSize)
+	vm.reg[0].value = uint32(len(image))
+	vm.pc.value = FirmwareAddr
+	return nil
+}
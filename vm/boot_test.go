@@ -0,0 +1,25 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBootImageTooLarge(t *testing.T) {
+	firmware := []byte{0xff}
+
+	err := NewVM().Boot(firmware, make([]byte, ScratchAddr-BootAddr))
+	if err != nil {
+		t.Fatalf("booting the largest image: %v", err)
+	}
+
+	err = NewVM().Boot(firmware, make([]byte, ScratchAddr-BootAddr+1))
+	if !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("got error %v for an image overlapping the scratch buffer, want %v", err, ErrImageTooLarge)
+	}
+
+	err = NewVM().Boot(make([]byte, FirmwareSize+1), nil)
+	if !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("got error %v for a firmware too large, want %v", err, ErrImageTooLarge)
+	}
+}
@@ -0,0 +1,114 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrPortInUse = errors.New("port already in use")
+
+// PortConsole is the port of the console device.
+const PortConsole = 0x20
+
+// Device is a peripheral that the guest talks to through I/O ports with VOUTB
+// and VINB.
+type Device interface {
+	// Ports returns the ports the device is attached to.
+	Ports() []byte
+
+	// In returns a byte read from the port.
+	In(vm *VM, port byte) byte
+
+	// Out writes a byte to the port.
+	Out(vm *VM, port byte, value byte)
+}
+
+// Ticker is implemented by devices that need to run as virtual time passes.
+type Ticker interface {
+	// Tick is called once per cycle (see VM.Cycles), including the cycles
+	// that pass while the machine is halted by VHLT. On a machine whose cores
+	// run in parallel, it is called with the devices locked.
+	Tick(vm *VM)
+}
+
+// AttachDevice attaches the device to the ports it reports. It fails if any of
+// the ports is already in use. If the device is a Ticker, it starts ticking.
+func (vm *VM) AttachDevice(dev Device) error {
+	for _, port := range dev.Ports() {
+		if _, ok := vm.ports[port]; ok {
+			return fmt.Errorf("%w: %#02x", ErrPortInUse, port)
+		}
+	}
+
+	for _, port := range dev.Ports() {
+		vm.ports[port] = dev
+	}
+
+	if ticker, ok := dev.(Ticker); ok {
+		vm.tickers = append(vm.tickers, ticker)
+	}
+
+	return nil
+}
+
+// in reads a byte from the port. Unattached ports read as 0.
+func (vm *VM) in(port byte) byte {
+	if vm.io != nil {
+		vm.io.Lock()
+		defer vm.io.Unlock()
+	}
+
+	return vm.portIn(port)
+}
+
+// out writes a byte to the port. Writes to unattached ports are ignored.
+func (vm *VM) out(port byte, value byte) {
+	if vm.io != nil {
+		vm.io.Lock()
+		defer vm.io.Unlock()
+	}
+
+	vm.portOut(port, value)
+}
+
+// portIn is like in, for callers that already hold the lock of the devices,
+// such as tickers.
+func (vm *VM) portIn(port byte) byte {
+	dev, ok := vm.ports[port]
+	if !ok {
+		return 0
+	}
+
+	return dev.In(vm, port)
+}
+
+// portOut is like out, for callers that already hold the lock of the devices.
+func (vm *VM) portOut(port byte, value byte) {
+	dev, ok := vm.ports[port]
+	if !ok {
+		return
+	}
+
+	dev.Out(vm, port, value)
+}
+
+// console writes the bytes sent by the guest to VM.Stdout.
+type console struct{}
+
+func (console) Ports() []byte {
+	return []byte{PortConsole}
+}
+
+func (console) In(vm *VM, port byte) byte {
+	return 0
+}
+
+func (console) Out(vm *VM, port byte, value byte) {
+	// TODO: Dumb implementation. A proper one should be interrupt-based.
+	//  See also: https://github.com/gynvael/zrozumiec-programowanie/blob/master/007-Czesc_II-Rozdzial_3-Podstawy_architektury_komputerowe/vm_dev_con.py
+
+	_, err := vm.Stdout.Write([]byte{value})
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+	}
+}
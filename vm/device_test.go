@@ -0,0 +1,43 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakeDevice struct {
+	ports []byte
+	out   []byte
+}
+
+func (d *fakeDevice) Ports() []byte { return d.ports }
+
+func (d *fakeDevice) In(vm *VM, port byte) byte { return port + 1 }
+
+func (d *fakeDevice) Out(vm *VM, port byte, value byte) { d.out = append(d.out, port, value) }
+
+func TestAttachDevice(t *testing.T) {
+	vm := NewVM()
+	dev := &fakeDevice{ports: []byte{0x50, 0x51}}
+
+	err := vm.AttachDevice(dev)
+	if err != nil {
+		t.Fatalf("attach device: %v", err)
+	}
+
+	vm.reg[1].value = 0x1234
+	VOUTB(vm, []byte{1, 0x51})
+	if len(dev.out) != 2 || dev.out[0] != 0x51 || dev.out[1] != 0x34 {
+		t.Errorf("got %x, want % x", dev.out, []byte{0x51, 0x34})
+	}
+
+	VINB(vm, []byte{2, 0x50})
+	if vm.reg[2].value != 0x51 {
+		t.Errorf("got %#x, want %#x", vm.reg[2].value, 0x51)
+	}
+
+	err = vm.AttachDevice(&fakeDevice{ports: []byte{0x20}})
+	if !errors.Is(err, ErrPortInUse) {
+		t.Errorf("got %v, want %v", err, ErrPortInUse)
+	}
+}
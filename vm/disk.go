@@ -0,0 +1,223 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// SectorSize is the size of a disk sector in bytes.
+const SectorSize = 512
+
+// Disk controller ports. The guest sets the sector number and the address of
+// the buffer in guest memory, then writes a command to PortDiskCommand. When
+// the command completes, IntDisk is raised and PortDiskCommand reads as the
+// status of the command.
+//
+// Alternatively, the sector can be accessed a byte at a time through
+// PortDiskData, starting at the beginning of the sector that was set last and
+// continuing into the following sectors. This is meant for the DMA controller.
+const (
+	PortDiskSectorLow  = 0x80
+	PortDiskSectorHigh = 0x81
+	PortDiskBufferLow  = 0x82
+	PortDiskBufferHigh = 0x83
+	PortDiskCommand    = 0x84
+	PortDiskData       = 0x85
+)
+
+// Disk controller commands.
+const (
+	DiskRead  = 1 // copy a sector from the disk to the buffer
+	DiskWrite = 2 // copy the buffer to a sector on the disk
+)
+
+// Disk controller statuses.
+const (
+	DiskStatusOK    = 0
+	DiskStatusError = 1
+)
+
+var ErrInvalidDiskImage = errors.New("invalid disk image")
+
+// Disk is a block storage device backed by a disk image file.
+type Disk struct {
+	image   *os.File
+	sectors int
+
+	sector uint16
+	buffer uint16
+	status byte
+	offset int64 // of the next byte accessed through PortDiskData
+}
+
+// OpenDisk opens the disk image file. Its size must be a multiple of
+// SectorSize.
+func OpenDisk(filename string) (*Disk, error) {
+	image, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open disk image: %w", err)
+	}
+
+	info, err := image.Stat()
+	if err != nil {
+		image.Close()
+		return nil, fmt.Errorf("stat disk image: %w", err)
+	}
+
+	if info.Size()%SectorSize != 0 {
+		image.Close()
+		return nil, fmt.Errorf("%w: size %d is not a multiple of %d", ErrInvalidDiskImage, info.Size(), SectorSize)
+	}
+
+	return &Disk{image: image, sectors: int(info.Size() / SectorSize)}, nil
+}
+
+// CreateDisk creates a zeroed disk image file with the given number of
+// sectors. If boot is not nil, it is written at the beginning of the image.
+func CreateDisk(filename string, sectors int, boot []byte) error {
+	if len(boot) > sectors*SectorSize {
+		return fmt.Errorf("%w: %d bytes do not fit in %d sectors", ErrInvalidDiskImage, len(boot), sectors)
+	}
+
+	image, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("create disk image: %w", err)
+	}
+	defer image.Close()
+
+	err = image.Truncate(int64(sectors) * SectorSize)
+	if err != nil {
+		return fmt.Errorf("resize disk image: %w", err)
+	}
+
+	_, err = image.WriteAt(boot, 0)
+	if err != nil {
+		return fmt.Errorf("write boot sector: %w", err)
+	}
+
+	return image.Close()
+}
+
+// Sectors returns the number of sectors on the disk.
+func (d *Disk) Sectors() int {
+	return d.sectors
+}
+
+// Close closes the disk image file.
+func (d *Disk) Close() error {
+	return d.image.Close()
+}
+
+func (d *Disk) Ports() []byte {
+	return []byte{PortDiskSectorLow, PortDiskSectorHigh, PortDiskBufferLow, PortDiskBufferHigh, PortDiskCommand, PortDiskData}
+}
+
+func (d *Disk) In(vm *VM, port byte) byte {
+	switch port {
+	case PortDiskSectorLow:
+		return byte(d.sector)
+	case PortDiskSectorHigh:
+		return byte(d.sector >> 8)
+	case PortDiskBufferLow:
+		return byte(d.buffer)
+	case PortDiskBufferHigh:
+		return byte(d.buffer >> 8)
+	case PortDiskData:
+		data := make([]byte, 1)
+		err := d.access(vm, func() error {
+			_, err := d.image.ReadAt(data, d.offset)
+			return err
+		})
+		if err != nil {
+			return 0
+		}
+
+		return data[0]
+	default:
+		return d.status
+	}
+}
+
+func (d *Disk) Out(vm *VM, port byte, value byte) {
+	switch port {
+	case PortDiskSectorLow:
+		d.sector = d.sector&0xff00 | uint16(value)
+		d.offset = int64(d.sector) * SectorSize
+	case PortDiskSectorHigh:
+		d.sector = d.sector&0x00ff | uint16(value)<<8
+		d.offset = int64(d.sector) * SectorSize
+	case PortDiskBufferLow:
+		d.buffer = d.buffer&0xff00 | uint16(value)
+	case PortDiskBufferHigh:
+		d.buffer = d.buffer&0x00ff | uint16(value)<<8
+	case PortDiskCommand:
+		err := d.command(vm, value)
+		if err != nil {
+			if vm.debug {
+				fmt.Printf("debug: disk command %d failed: %v\n", value, err)
+			}
+			d.status = DiskStatusError
+		} else {
+			d.status = DiskStatusOK
+		}
+
+		vm.interrupt(IntDisk)
+	case PortDiskData:
+		_ = d.access(vm, func() error {
+			_, err := d.image.WriteAt([]byte{value}, d.offset)
+			return err
+		})
+	}
+}
+
+// access performs a single byte access through PortDiskData and sets the
+// status. No interrupt is raised.
+func (d *Disk) access(vm *VM, f func() error) error {
+	var err error
+	if d.offset >= int64(d.sectors)*SectorSize {
+		err = fmt.Errorf("offset %d out of range", d.offset)
+	} else {
+		err = f()
+	}
+
+	if err != nil {
+		if vm.debug {
+			fmt.Printf("debug: disk data access failed: %v\n", err)
+		}
+		d.status = DiskStatusError
+		return err
+	}
+
+	d.offset++
+	d.status = DiskStatusOK
+	return nil
+}
+
+func (d *Disk) command(vm *VM, command byte) error {
+	if int(d.sector) >= d.sectors {
+		return fmt.Errorf("sector %d out of range", d.sector)
+	}
+
+	offset := int64(d.sector) * SectorSize
+	switch command {
+	case DiskRead:
+		data := make([]byte, SectorSize)
+		_, err := d.image.ReadAt(data, offset)
+		if err != nil {
+			return err
+		}
+
+		return vm.memory.StoreMany(d.buffer, data)
+	case DiskWrite:
+		data, err := vm.memory.FetchMany(d.buffer, SectorSize)
+		if err != nil {
+			return err
+		}
+
+		_, err = d.image.WriteAt(data, offset)
+		return err
+	default:
+		return fmt.Errorf("invalid command %d", command)
+	}
+}
@@ -0,0 +1,79 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDisk(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "disk.img")
+	boot := bytes.Repeat([]byte{0xaa}, SectorSize+1)
+	err := CreateDisk(filename, 4, boot)
+	if err != nil {
+		t.Fatalf("create disk: %v", err)
+	}
+
+	disk, err := OpenDisk(filename)
+	if err != nil {
+		t.Fatalf("open disk: %v", err)
+	}
+	defer disk.Close()
+
+	vm := NewVM()
+	err = vm.AttachDevice(disk)
+	if err != nil {
+		t.Fatalf("attach disk: %v", err)
+	}
+
+	// Read sector 1 to 0x1000.
+	disk.Out(vm, PortDiskSectorLow, 1)
+	disk.Out(vm, PortDiskSectorHigh, 0)
+	disk.Out(vm, PortDiskBufferLow, 0x00)
+	disk.Out(vm, PortDiskBufferHigh, 0x10)
+	disk.Out(vm, PortDiskCommand, DiskRead)
+
+	if status := disk.In(vm, PortDiskCommand); status != DiskStatusOK {
+		t.Errorf("got status %d, want %d", status, DiskStatusOK)
+	}
+	if len(vm.interruptQueue) != 1 || vm.interruptQueue[0] != IntDisk {
+		t.Errorf("got interrupts %v, want %v", vm.interruptQueue, []int{IntDisk})
+	}
+
+	got, _ := vm.memory.FetchMany(0x1000, 2)
+	if !bytes.Equal(got, []byte{0xaa, 0x00}) {
+		t.Errorf("got % x, want % x", got, []byte{0xaa, 0x00})
+	}
+
+	// Write it back to sector 3.
+	vm.memory.mem[0x1001] = 0xbb
+	disk.Out(vm, PortDiskSectorLow, 3)
+	disk.Out(vm, PortDiskCommand, DiskWrite)
+
+	data, _ := os.ReadFile(filename)
+	if !bytes.Equal(data[3*SectorSize:3*SectorSize+2], []byte{0xaa, 0xbb}) {
+		t.Errorf("got % x, want % x", data[3*SectorSize:3*SectorSize+2], []byte{0xaa, 0xbb})
+	}
+
+	// Sector 4 does not exist.
+	disk.Out(vm, PortDiskSectorLow, 4)
+	disk.Out(vm, PortDiskCommand, DiskRead)
+	if status := disk.In(vm, PortDiskCommand); status != DiskStatusError {
+		t.Errorf("got status %d, want %d", status, DiskStatusError)
+	}
+}
+
+func TestOpenDiskInvalidSize(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "disk.img")
+	err := os.WriteFile(filename, make([]byte, SectorSize+1), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = OpenDisk(filename)
+	if !errors.Is(err, ErrInvalidDiskImage) {
+		t.Errorf("got %v, want %v", err, ErrInvalidDiskImage)
+	}
+}
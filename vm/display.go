@@ -0,0 +1,138 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Text display geometry and location in memory. Each cell is a character byte
+// followed by an attribute byte. The low nibble of the attribute is the
+// foreground color and bits 4–6 are the background color, in the VGA order:
+// black, blue, green, cyan, red, magenta, brown, light gray. Bit 3 makes the
+// foreground color bright.
+const (
+	TextDisplayAddr    = 0xb800
+	TextDisplayColumns = 80
+	TextDisplayRows    = 25
+	TextDisplaySize    = TextDisplayColumns * TextDisplayRows * 2
+)
+
+// PortDisplayControl is the port of the text display. Writing
+// DisplayRefresh to it renders the screen.
+const PortDisplayControl = 0x90
+
+const DisplayRefresh = 1
+
+// vgaToANSI maps VGA color numbers to ANSI color numbers.
+var vgaToANSI = [8]int{0, 4, 2, 6, 1, 5, 3, 7}
+
+// TextDisplay is a text-mode display mapped into memory at TextDisplayAddr.
+// It is either rendered to a terminal with ANSI escape codes or, in headless
+// mode, saved to text files.
+type TextDisplay struct {
+	out         io.Writer
+	cleared     bool
+	snapshotDir string
+	snapshots   int
+}
+
+// NewTextDisplay creates a text display rendered to the terminal out.
+func NewTextDisplay(out io.Writer) *TextDisplay {
+	return &TextDisplay{out: out}
+}
+
+// NewHeadlessTextDisplay creates a text display that saves each rendered
+// screen to a numbered text file in dir.
+func NewHeadlessTextDisplay(dir string) *TextDisplay {
+	return &TextDisplay{snapshotDir: dir}
+}
+
+func (d *TextDisplay) Ports() []byte {
+	return []byte{PortDisplayControl}
+}
+
+func (d *TextDisplay) In(vm *VM, port byte) byte {
+	return 0
+}
+
+func (d *TextDisplay) Out(vm *VM, port byte, value byte) {
+	if value != DisplayRefresh {
+		return
+	}
+
+	err := d.Render(vm)
+	if err != nil && vm.debug {
+		fmt.Printf("debug: failed to render text display: %v\n", err)
+	}
+}
+
+// Render renders the current contents of the display.
+func (d *TextDisplay) Render(vm *VM) error {
+	cells, err := vm.memory.FetchMany(TextDisplayAddr, TextDisplaySize)
+	if err != nil {
+		return fmt.Errorf("fetch display memory: %w", err)
+	}
+
+	if d.out == nil {
+		return d.snapshot(cells)
+	}
+
+	var buf bytes.Buffer
+	if !d.cleared {
+		buf.WriteString("\x1b[2J")
+		d.cleared = true
+	}
+	buf.WriteString("\x1b[H")
+	attr := -1
+	for row := range TextDisplayRows {
+		for col := range TextDisplayColumns {
+			i := 2 * (row*TextDisplayColumns + col)
+			if int(cells[i+1]) != attr {
+				attr = int(cells[i+1])
+				fg := 30 + vgaToANSI[attr&7]
+				if attr&8 != 0 {
+					fg += 60
+				}
+				bg := 40 + vgaToANSI[(attr>>4)&7]
+				fmt.Fprintf(&buf, "\x1b[%d;%dm", fg, bg)
+			}
+
+			buf.WriteByte(printable(cells[i]))
+		}
+
+		buf.WriteString("\x1b[0m\r\n")
+		attr = -1
+	}
+
+	_, err = d.out.Write(buf.Bytes())
+	return err
+}
+
+func (d *TextDisplay) snapshot(cells []byte) error {
+	var buf bytes.Buffer
+	for row := range TextDisplayRows {
+		line := make([]byte, TextDisplayColumns)
+		for col := range line {
+			line[col] = printable(cells[2*(row*TextDisplayColumns+col)])
+		}
+
+		buf.Write(bytes.TrimRight(line, " "))
+		buf.WriteByte('\n')
+	}
+
+	d.snapshots++
+	filename := filepath.Join(d.snapshotDir, fmt.Sprintf("screen-%04d.txt", d.snapshots))
+	return os.WriteFile(filename, buf.Bytes(), 0o644)
+}
+
+// printable returns the character, or a space if it cannot be displayed.
+func printable(c byte) byte {
+	if c < 0x20 || c >= 0x7f {
+		return ' '
+	}
+
+	return c
+}
@@ -0,0 +1,55 @@
+package vm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTextDisplay(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	display := NewTextDisplay(out)
+
+	vm := NewVM()
+	vm.memory.mem[TextDisplayAddr] = 'H'
+	vm.memory.mem[TextDisplayAddr+1] = 0x1e // yellow on blue
+	vm.memory.mem[TextDisplayAddr+2] = 'i'
+	vm.memory.mem[TextDisplayAddr+3] = 0x1e
+
+	display.Out(vm, PortDisplayControl, DisplayRefresh)
+
+	got := out.String()
+	want := "\x1b[2J\x1b[H\x1b[93;44mHi\x1b[30;40m "
+	if len(got) < len(want) {
+		t.Fatalf("got %d bytes of output %q, want at least %d", len(got), got, len(want))
+	}
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("got %q, want prefix %q", got[:len(want)], want)
+	}
+	if lines := strings.Count(got, "\r\n"); lines != TextDisplayRows {
+		t.Errorf("got %d lines, want %d", lines, TextDisplayRows)
+	}
+}
+
+func TestHeadlessTextDisplay(t *testing.T) {
+	dir := t.TempDir()
+	display := NewHeadlessTextDisplay(dir)
+
+	vm := NewVM()
+	_ = vm.memory.StoreMany(TextDisplayAddr+2*TextDisplayColumns, []byte{'O', 7, 'K', 7})
+
+	display.Out(vm, PortDisplayControl, DisplayRefresh)
+	display.Out(vm, PortDisplayControl, DisplayRefresh)
+
+	data, err := os.ReadFile(filepath.Join(dir, "screen-0002.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\nOK\n" + strings.Repeat("\n", TextDisplayRows-2)
+	if string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,181 @@
+package vm
+
+import "fmt"
+
+// DMA controller ports. The guest sets the addresses, the count and, depending
+// on the command, the fill value or the port of a device, then writes a
+// command to PortDMACommand. While the transfer is in progress,
+// PortDMACommand reads as DMAStatusBusy. When it completes, IntDMA is raised
+// and PortDMACommand reads as the status of the transfer.
+const (
+	PortDMASourceLow  = 0xe0
+	PortDMASourceHigh = 0xe1
+	PortDMADestLow    = 0xe2
+	PortDMADestHigh   = 0xe3
+	PortDMACountLow   = 0xe4
+	PortDMACountHigh  = 0xe5
+	PortDMAValue      = 0xe6 // fill value or device port
+	PortDMACommand    = 0xe7
+)
+
+// DMA controller commands. Memory is accessed forward, a byte at a time, and
+// addresses wrap around at the end of memory.
+const (
+	DMACopy     = 1 // copy count bytes from source to destination
+	DMAFill     = 2 // fill count bytes at destination with the value
+	DMAToPort   = 3 // write count bytes from source to the port
+	DMAFromPort = 4 // read count bytes from the port to destination
+)
+
+// DMA controller statuses.
+const (
+	DMAStatusOK    = 0
+	DMAStatusError = 1
+	DMAStatusBusy  = 2
+)
+
+// DMABytesPerCycle is the number of bytes the DMA controller transfers per
+// cycle.
+const DMABytesPerCycle = 16
+
+// DMA is a controller that transfers data between memory ranges, or between
+// memory and the port of another device, while the guest keeps running.
+// Stores honour memory protection: a store to read-only memory stops the
+// transfer with an error.
+type DMA struct {
+	source  uint16
+	dest    uint16
+	count   uint16
+	value   byte
+	command byte
+	status  byte
+}
+
+// NewDMA creates a DMA controller.
+func NewDMA() *DMA {
+	return &DMA{}
+}
+
+func (d *DMA) Ports() []byte {
+	return []byte{
+		PortDMASourceLow, PortDMASourceHigh, PortDMADestLow, PortDMADestHigh,
+		PortDMACountLow, PortDMACountHigh, PortDMAValue, PortDMACommand,
+	}
+}
+
+func (d *DMA) In(vm *VM, port byte) byte {
+	switch port {
+	case PortDMASourceLow:
+		return byte(d.source)
+	case PortDMASourceHigh:
+		return byte(d.source >> 8)
+	case PortDMADestLow:
+		return byte(d.dest)
+	case PortDMADestHigh:
+		return byte(d.dest >> 8)
+	case PortDMACountLow:
+		return byte(d.count)
+	case PortDMACountHigh:
+		return byte(d.count >> 8)
+	case PortDMAValue:
+		return d.value
+	default:
+		return d.status
+	}
+}
+
+func (d *DMA) Out(vm *VM, port byte, value byte) {
+	if d.status == DMAStatusBusy {
+		return
+	}
+
+	switch port {
+	case PortDMASourceLow:
+		d.source = d.source&0xff00 | uint16(value)
+	case PortDMASourceHigh:
+		d.source = d.source&0x00ff | uint16(value)<<8
+	case PortDMADestLow:
+		d.dest = d.dest&0xff00 | uint16(value)
+	case PortDMADestHigh:
+		d.dest = d.dest&0x00ff | uint16(value)<<8
+	case PortDMACountLow:
+		d.count = d.count&0xff00 | uint16(value)
+	case PortDMACountHigh:
+		d.count = d.count&0x00ff | uint16(value)<<8
+	case PortDMAValue:
+		d.value = value
+	case PortDMACommand:
+		switch value {
+		case DMACopy, DMAFill, DMAToPort, DMAFromPort:
+			d.command = value
+			d.status = DMAStatusBusy
+		default:
+			d.finish(vm, fmt.Errorf("invalid command %d", value))
+		}
+	}
+}
+
+// Tick advances the transfer in progress, so that it takes virtual time
+// proportional to its size.
+func (d *DMA) Tick(vm *VM) {
+	if d.status != DMAStatusBusy {
+		return
+	}
+
+	for range DMABytesPerCycle {
+		if d.count == 0 {
+			d.finish(vm, nil)
+			return
+		}
+
+		err := d.step(vm)
+		if err != nil {
+			d.finish(vm, err)
+			return
+		}
+	}
+}
+
+// step transfers a single byte.
+func (d *DMA) step(vm *VM) error {
+	var b byte
+	switch d.command {
+	case DMACopy, DMAToPort:
+		var err error
+		b, err = vm.memory.FetchByte(d.source)
+		if err != nil {
+			return err
+		}
+		d.source++
+	case DMAFill:
+		b = d.value
+	case DMAFromPort:
+		b = vm.portIn(d.value)
+	}
+
+	if d.command == DMAToPort {
+		vm.portOut(d.value, b)
+	} else {
+		err := vm.memory.StoreByte(d.dest, b)
+		if err != nil {
+			return err
+		}
+		d.dest++
+	}
+
+	d.count--
+	return nil
+}
+
+func (d *DMA) finish(vm *VM, err error) {
+	if err != nil {
+		if vm.debug {
+			fmt.Printf("debug: DMA command %d failed: %v\n", d.command, err)
+		}
+		d.status = DMAStatusError
+	} else {
+		d.status = DMAStatusOK
+	}
+
+	vm.interrupt(IntDMA)
+}
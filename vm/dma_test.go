@@ -0,0 +1,163 @@
+package vm
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+// startDMA programs the DMA controller and runs it until the transfer
+// completes. It returns the number of cycles the transfer took.
+func startDMA(t *testing.T, vm *VM, dma *DMA, source, dest, count uint16, value, command byte) uint64 {
+	t.Helper()
+
+	for port, b := range map[byte]byte{
+		PortDMASourceLow:  byte(source),
+		PortDMASourceHigh: byte(source >> 8),
+		PortDMADestLow:    byte(dest),
+		PortDMADestHigh:   byte(dest >> 8),
+		PortDMACountLow:   byte(count),
+		PortDMACountHigh:  byte(count >> 8),
+		PortDMAValue:      value,
+	} {
+		dma.Out(vm, port, b)
+	}
+
+	dma.Out(vm, PortDMACommand, command)
+	start := vm.cycles
+	for dma.In(vm, PortDMACommand) == DMAStatusBusy {
+		vm.cycles++
+		dma.Tick(vm)
+	}
+
+	return vm.cycles - start
+}
+
+func TestDMACopyAndFill(t *testing.T) {
+	vm := NewVM()
+	dma := NewDMA()
+	_ = vm.AttachDevice(dma)
+
+	_ = vm.memory.StoreMany(0x1000, []byte("hello, world"))
+	cycles := startDMA(t, vm, dma, 0x1000, 0x2000, 12, 0, DMACopy)
+	if got, _ := vm.memory.FetchMany(0x2000, 12); !bytes.Equal(got, []byte("hello, world")) {
+		t.Errorf("got %q, want %q", got, "hello, world")
+	}
+	if cycles != 1 {
+		t.Errorf("got %d cycles, want 1", cycles)
+	}
+	if len(vm.interruptQueue) != 1 || vm.interruptQueue[0] != IntDMA {
+		t.Errorf("got interrupts %v, want %v", vm.interruptQueue, []int{IntDMA})
+	}
+
+	cycles = startDMA(t, vm, dma, 0, 0x3000, 100, 0xee, DMAFill)
+	if got, _ := vm.memory.FetchMany(0x3000, 101); !bytes.Equal(got, append(bytes.Repeat([]byte{0xee}, 100), 0)) {
+		t.Errorf("got % x, want 100 bytes of ee", got)
+	}
+	if want := uint64(100/DMABytesPerCycle + 1); cycles != want {
+		t.Errorf("got %d cycles, want %d", cycles, want)
+	}
+	if status := dma.In(vm, PortDMACommand); status != DMAStatusOK {
+		t.Errorf("got status %d, want %d", status, DMAStatusOK)
+	}
+}
+
+func TestDMAReadOnly(t *testing.T) {
+	vm := NewVM()
+	dma := NewDMA()
+	_ = vm.AttachDevice(dma)
+	vm.memory.Protect(0x2008, 8)
+
+	startDMA(t, vm, dma, 0, 0x2000, 16, 0xee, DMAFill)
+	if status := dma.In(vm, PortDMACommand); status != DMAStatusError {
+		t.Errorf("got status %d, want %d", status, DMAStatusError)
+	}
+	if got, _ := vm.memory.FetchByte(0x2008); got != 0 {
+		t.Errorf("got %#x in read-only memory, want 0", got)
+	}
+}
+
+func TestDMAPorts(t *testing.T) {
+	vm := NewVM()
+	dma := NewDMA()
+	_ = vm.AttachDevice(dma)
+	dev := &fakeDevice{ports: []byte{0x50}}
+	_ = vm.AttachDevice(dev)
+
+	_ = vm.memory.StoreMany(0x1000, []byte{1, 2, 3})
+	startDMA(t, vm, dma, 0x1000, 0, 3, 0x50, DMAToPort)
+	if want := []byte{0x50, 1, 0x50, 2, 0x50, 3}; !bytes.Equal(dev.out, want) {
+		t.Errorf("got % x, want % x", dev.out, want)
+	}
+
+	filename := filepath.Join(t.TempDir(), "disk.img")
+	err := CreateDisk(filename, 2, []byte("boot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disk, err := OpenDisk(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disk.Close()
+	_ = vm.AttachDevice(disk)
+
+	// Overwrite the beginning of sector 0, then read it back.
+	_ = vm.memory.StoreMany(0x1000, []byte("copy"))
+	disk.Out(vm, PortDiskSectorLow, 0)
+	startDMA(t, vm, dma, 0x1000, 0, 4, PortDiskData, DMAToPort)
+	disk.Out(vm, PortDiskSectorLow, 0)
+	startDMA(t, vm, dma, 0, 0x2000, 6, PortDiskData, DMAFromPort)
+	if got, _ := vm.memory.FetchMany(0x2000, 6); !bytes.Equal(got, []byte("copy\x00\x00")) {
+		t.Errorf("got %q, want %q", got, "copy\x00\x00")
+	}
+	if status := disk.In(vm, PortDiskCommand); status != DiskStatusOK {
+		t.Errorf("got disk status %d, want %d", status, DiskStatusOK)
+	}
+}
+
+func TestDMAPortsParallel(t *testing.T) {
+	m := setUpMachine(t, 2, `
+	vset r0, 0x00
+	voutb 0xe0, r0
+	vset r0, 0x10
+	voutb 0xe1, r0
+	vset r0, 5
+	voutb 0xe4, r0
+	vset r0, 0
+	voutb 0xe5, r0
+	vset r0, 0x20
+	voutb 0xe6, r0
+	vset r0, 3
+	voutb 0xe7, r0
+
+	vset r2, 2
+wait:
+	vinb 0xe7, r1
+	vcmp r1, r2
+	vjz wait
+	voff
+
+	times 0x1000-($-$$) db 0
+	db "hello"
+`)
+	m.Scheduler = Parallel
+
+	var out bytes.Buffer
+	core := m.Core(0)
+	core.Stdout = &out
+	err := core.AttachDevice(NewDMA())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "hello" {
+		t.Errorf("got output %q, want %q", out.String(), "hello")
+	}
+}
@@ -0,0 +1,193 @@
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Framebuffer geometry and location in memory. Each pixel is a byte that
+// indexes the palette. 320x200 would not fit in the 64 KB address space, so the
+// resolution is halved in both directions.
+const (
+	FramebufferAddr   = 0x4000
+	FramebufferWidth  = 160
+	FramebufferHeight = 100
+	FramebufferSize   = FramebufferWidth * FramebufferHeight
+)
+
+// Framebuffer ports. To change a palette entry, the guest writes its index to
+// PortPaletteIndex, then the red, green and blue components to
+// PortPaletteData. The index is incremented after every entry, so consecutive
+// entries can be written without setting it again.
+const (
+	PortPaletteIndex       = 0xa0
+	PortPaletteData        = 0xa1
+	PortFramebufferControl = 0xa2 // writing FramebufferCapture captures a frame
+)
+
+const FramebufferCapture = 1
+
+// ImageFormat is the file format of captured frames.
+type ImageFormat string
+
+const (
+	FormatPNG ImageFormat = "png"
+	FormatPPM ImageFormat = "ppm"
+)
+
+// Framebuffer is a graphics device with a linear framebuffer mapped into
+// memory at FramebufferAddr and a palette of 256 colors. Frames are captured
+// to numbered image files.
+type Framebuffer struct {
+	// Format is the format of captured frames.
+	Format ImageFormat
+	// CaptureEvery, if not zero, makes the framebuffer capture a frame every
+	// CaptureEvery cycles, in addition to the captures requested by the guest.
+	CaptureEvery uint64
+
+	dir       string
+	palette   color.Palette
+	index     byte
+	component int // of the palette entry written next
+	captures  int
+}
+
+// NewFramebuffer creates a framebuffer that saves captured frames in dir, in
+// the PNG format.
+//
+// The default palette is 3-3-2 RGB: bits 5–7 of a pixel are red, bits 2–4
+// are green and bits 0–1 are blue.
+func NewFramebuffer(dir string) *Framebuffer {
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		palette[i] = color.RGBA{
+			R: uint8((i >> 5) * 255 / 7),
+			G: uint8((i >> 2 & 7) * 255 / 7),
+			B: uint8((i & 3) * 255 / 3),
+			A: 0xff,
+		}
+	}
+
+	return &Framebuffer{Format: FormatPNG, dir: dir, palette: palette}
+}
+
+func (f *Framebuffer) Ports() []byte {
+	return []byte{PortPaletteIndex, PortPaletteData, PortFramebufferControl}
+}
+
+func (f *Framebuffer) In(vm *VM, port byte) byte {
+	switch port {
+	case PortPaletteIndex:
+		return f.index
+	case PortPaletteData:
+		c := f.palette[f.index].(color.RGBA)
+		return [3]byte{c.R, c.G, c.B}[f.component]
+	default:
+		return 0
+	}
+}
+
+func (f *Framebuffer) Out(vm *VM, port byte, value byte) {
+	switch port {
+	case PortPaletteIndex:
+		f.index = value
+		f.component = 0
+	case PortPaletteData:
+		c := f.palette[f.index].(color.RGBA)
+		switch f.component {
+		case 0:
+			c.R = value
+		case 1:
+			c.G = value
+		case 2:
+			c.B = value
+		}
+		f.palette[f.index] = c
+
+		f.component++
+		if f.component == 3 {
+			f.component = 0
+			f.index++
+		}
+	case PortFramebufferControl:
+		if value == FramebufferCapture {
+			f.capture(vm)
+		}
+	}
+}
+
+func (f *Framebuffer) Tick(vm *VM) {
+	if f.CaptureEvery != 0 && vm.cycles%f.CaptureEvery == 0 {
+		f.capture(vm)
+	}
+}
+
+// Frame returns the current contents of the framebuffer.
+func (f *Framebuffer) Frame(vm *VM) (*image.Paletted, error) {
+	pixels, err := vm.memory.FetchMany(FramebufferAddr, FramebufferSize)
+	if err != nil {
+		return nil, fmt.Errorf("fetch framebuffer memory: %w", err)
+	}
+
+	palette := make(color.Palette, len(f.palette))
+	copy(palette, f.palette)
+
+	frame := image.NewPaletted(image.Rect(0, 0, FramebufferWidth, FramebufferHeight), palette)
+	copy(frame.Pix, pixels)
+	return frame, nil
+}
+
+func (f *Framebuffer) capture(vm *VM) {
+	err := f.Capture(vm)
+	if err != nil && vm.debug {
+		fmt.Printf("debug: failed to capture frame: %v\n", err)
+	}
+}
+
+// Capture saves the current contents of the framebuffer to the next numbered
+// image file.
+func (f *Framebuffer) Capture(vm *VM) error {
+	frame, err := f.Frame(vm)
+	if err != nil {
+		return err
+	}
+
+	f.captures++
+	filename := filepath.Join(f.dir, fmt.Sprintf("frame-%04d.%s", f.captures, f.Format))
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("create frame file: %w", err)
+	}
+	defer file.Close()
+
+	switch f.Format {
+	case FormatPPM:
+		err = encodePPM(file, frame)
+	default:
+		err = png.Encode(file, frame)
+	}
+	if err != nil {
+		return fmt.Errorf("encode frame: %w", err)
+	}
+
+	return file.Close()
+}
+
+// encodePPM writes the image in the binary PPM (P6) format.
+func encodePPM(out io.Writer, frame *image.Paletted) error {
+	w := bufio.NewWriter(out)
+	bounds := frame.Bounds()
+	fmt.Fprintf(w, "P6\n%d %d\n255\n", bounds.Dx(), bounds.Dy())
+	for _, i := range frame.Pix {
+		c := frame.Palette[i].(color.RGBA)
+		w.Write([]byte{c.R, c.G, c.B})
+	}
+
+	return w.Flush()
+}
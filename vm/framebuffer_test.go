@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFramebufferPalette(t *testing.T) {
+	fb := NewFramebuffer(t.TempDir())
+	vm := NewVM()
+
+	fb.Out(vm, PortPaletteIndex, 7)
+	for _, component := range []byte{1, 2, 3, 4, 5, 6} {
+		fb.Out(vm, PortPaletteData, component)
+	}
+
+	vm.memory.mem[FramebufferAddr] = 7
+	vm.memory.mem[FramebufferAddr+FramebufferWidth+1] = 8
+
+	frame, err := fb.Frame(vm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{x: 0, y: 0, want: color.RGBA{R: 1, G: 2, B: 3, A: 0xff}},
+		{x: 1, y: 1, want: color.RGBA{R: 4, G: 5, B: 6, A: 0xff}},
+		{x: 2, y: 2, want: color.RGBA{A: 0xff}},
+	}
+
+	for _, tc := range testCases {
+		got := frame.At(tc.x, tc.y)
+		if got != tc.want {
+			t.Errorf("(%d, %d): got %v, want %v", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestFramebufferCapture(t *testing.T) {
+	dir := t.TempDir()
+	fb := NewFramebuffer(dir)
+	vm := NewVM()
+	vm.memory.mem[FramebufferAddr] = 0xe0 // bright red
+
+	fb.Out(vm, PortFramebufferControl, FramebufferCapture)
+	data, err := os.ReadFile(filepath.Join(dir, "frame-0001.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := img.At(0, 0).RGBA(); r != 0xffff || g != 0 || b != 0 {
+		t.Errorf("got (%x, %x, %x), want (ffff, 0, 0)", r, g, b)
+	}
+
+	fb.Format = FormatPPM
+	fb.Out(vm, PortFramebufferControl, FramebufferCapture)
+	data, err = os.ReadFile(filepath.Join(dir, "frame-0002.ppm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := "P6\n160 100\n255\n"
+	if !bytes.HasPrefix(data, []byte(header+"\xff\x00\x00")) {
+		t.Errorf("got %q, want prefix %q", data[:len(header)+3], header+"\xff\x00\x00")
+	}
+	if len(data) != len(header)+3*FramebufferSize {
+		t.Errorf("got %d bytes, want %d", len(data), len(header)+3*FramebufferSize)
+	}
+}
+
+func TestFramebufferCaptureEvery(t *testing.T) {
+	dir := t.TempDir()
+	fb := NewFramebuffer(dir)
+	fb.CaptureEvery = 2
+
+	vm := NewVM()
+	_ = vm.AttachDevice(fb)
+	for range 5 {
+		err := vm.runSingleStep()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.png"))
+	if len(files) != 2 {
+		t.Errorf("got %d frames, want 2", len(files))
+	}
+}
@@ -0,0 +1,66 @@
+package vm
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrHaltedForever is returned by Run when the machine executes VHLT while no
+// interrupt can ever wake it: maskable interrupts are disabled and no devices
+// tick.
+var ErrHaltedForever = errors.New("halted forever")
+
+// haltQuantum is the number of cycles a halted machine lets pass before it
+// sleeps for the time they take at ClockRate.
+const haltQuantum = ClockRate / 1000
+
+// interruptPending reports whether an interrupt can be delivered.
+func (vm *VM) interruptPending() bool {
+	vm.interruptQueueMutex.Lock()
+	defer vm.interruptQueueMutex.Unlock()
+
+	for _, interrupt := range vm.interruptQueue {
+		if vm.deliverable(interrupt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// canWake reports whether an interrupt can still end VHLT. Devices that tick
+// can raise interrupts as time passes, non-maskable ones too. Anything else,
+// such as other goroutines or cores, can raise only maskable interrupts.
+func (vm *VM) canWake() bool {
+	return len(vm.tickers) != 0 || vm.creg[CregIntContrl]&IntContrlEnable != 0
+}
+
+// shouldSleep reports whether the run loop should sleep after the step it
+// has just taken. A halted machine with devices attached lets haltQuantum
+// cycles pass between sleeps, so that virtual time keeps up with the host
+// clock. Without devices, nothing happens as time passes, so it sleeps right
+// away.
+func (vm *VM) shouldSleep() bool {
+	return vm.halted && (len(vm.tickers) == 0 || vm.haltedCycles-vm.sleptAt >= haltQuantum)
+}
+
+// sleep blocks until an interrupt is raised or done is closed. If devices are
+// attached, it returns after the time haltQuantum cycles take at the latest.
+func (vm *VM) sleep(done <-chan struct{}) {
+	start := time.Now()
+	defer func() { vm.haltedTime += time.Since(start) }()
+	vm.sleptAt = vm.haltedCycles
+
+	var timeout <-chan time.Time
+	if len(vm.tickers) != 0 {
+		timer := time.NewTimer(time.Second * haltQuantum / ClockRate)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-vm.wakeup:
+	case <-timeout:
+	case <-done:
+	}
+}
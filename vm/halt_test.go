@@ -0,0 +1,92 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// setUpHalt creates a machine that halts at address 0 and powers off in the
+// handler of the interrupt.
+func setUpHalt(interrupt int) *VM {
+	vm := NewVM()
+	vm.memory.mem[0] = 0xf6 // VHLT
+	vm.memory.mem[1] = 0x40 // VJMP $
+	vm.memory.mem[2] = 0xfd
+	vm.memory.mem[3] = 0xff
+	vm.memory.mem[0x10] = 0xff // VOFF
+	vm.creg[CregIntFirst+interrupt] = 0x10
+	vm.creg[CregIntContrl] = IntContrlEnable
+	return vm
+}
+
+func TestHaltSleepsUntilInterrupt(t *testing.T) {
+	vm := setUpHalt(IntIpi)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		vm.interrupt(IntIpi)
+	}()
+
+	err := vm.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := vm.Stats()
+	if stats.Instructions != 2 || stats.HaltedCycles != 0 {
+		t.Errorf("got %d instructions and %d halted cycles, want 2 and 0", stats.Instructions, stats.HaltedCycles)
+	}
+	if stats.HaltedTime < 10*time.Millisecond {
+		t.Errorf("got %v halted, want at least 10ms", stats.HaltedTime)
+	}
+}
+
+func TestHaltTicksDevices(t *testing.T) {
+	vm := setUpHalt(IntWatchdog)
+	wd := NewWatchdog()
+	_ = vm.AttachDevice(wd)
+	wd.Out(vm, PortWatchdogTimeout, 0xc4) // 2500
+	wd.Out(vm, PortWatchdogTimeout+1, 0x09)
+	wd.Out(vm, PortWatchdogControl, WatchdogArm)
+
+	err := vm.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := vm.Stats()
+	if stats.Instructions != 2 || stats.HaltedCycles != 2499 || stats.Cycles != 2501 {
+		t.Errorf("got %+v, want 2 instructions and 2499 halted cycles", stats)
+	}
+	if stats.HaltedTime < 2*time.Millisecond {
+		t.Errorf("got %v halted, want at least 2ms", stats.HaltedTime)
+	}
+}
+
+func TestHaltIgnoresMaskedInterrupts(t *testing.T) {
+	vm := setUpHalt(IntIpi)
+	vm.creg[CregIntContrl] = 0
+	_ = vm.AttachDevice(NewWatchdog()) // so that the machine can still wake
+	vm.interrupt(IntIpi)
+
+	for range 3 {
+		err := vm.runSingleStep()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !vm.halted || vm.pc.value != 1 {
+		t.Errorf("got halted %t at pc %#x, want halted at 0x1", vm.halted, vm.pc.value)
+	}
+}
+
+func TestHaltForever(t *testing.T) {
+	vm := setUpHalt(IntIpi)
+	vm.creg[CregIntContrl] = 0
+
+	err := vm.Run()
+	if !errors.Is(err, ErrHaltedForever) {
+		t.Errorf("got error %v, want %v", err, ErrHaltedForever)
+	}
+}
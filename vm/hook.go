@@ -0,0 +1,138 @@
+package vm
+
+import "errors"
+
+// InstructionHook observes the execution of instructions.
+type InstructionHook interface {
+	// BeforeInstruction is called before the instruction with the opcode at
+	// pc is executed.
+	BeforeInstruction(vm *VM, pc uint16, opcode byte)
+
+	// AfterInstruction is called after the instruction with the opcode at pc
+	// is executed, before virtual time advances.
+	AfterInstruction(vm *VM, pc uint16, opcode byte)
+}
+
+// MemoryHook observes the accesses of instructions and interrupts to memory.
+// Fetching instructions and transfers made by devices are not reported.
+type MemoryHook interface {
+	// OnMemoryRead is called after size bytes at addr are read.
+	OnMemoryRead(vm *VM, addr uint16, size int, value uint32)
+
+	// OnMemoryWrite is called after size bytes at addr are written.
+	OnMemoryWrite(vm *VM, addr uint16, size int, value uint32)
+}
+
+// InterruptHook observes interrupts.
+type InterruptHook interface {
+	// OnInterrupt is called when the machine enters the handler of the
+	// interrupt with the vector.
+	OnInterrupt(vm *VM, vector int)
+}
+
+// PortHook observes I/O with VOUTB and VINB.
+type PortHook interface {
+	// OnPortIO is called after a byte is written to the port, if out is true,
+	// or read from it, including ports with no device attached.
+	OnPortIO(vm *VM, port byte, value byte, out bool)
+}
+
+// hooks are the hooks of a machine, by the interfaces they implement.
+type hooks struct {
+	instruction []InstructionHook
+	memory      []MemoryHook
+	interrupt   []InterruptHook
+	port        []PortHook
+}
+
+// AddHook adds a hook that implements any of InstructionHook, MemoryHook,
+// InterruptHook and PortHook. It fails if the hook implements none of them.
+//
+// Hooks are called on the goroutine that runs the machine. A machine with no
+// hooks does not pay for them.
+func (vm *VM) AddHook(hook any) error {
+	added := false
+	if h, ok := hook.(InstructionHook); ok {
+		vm.hooks.instruction = append(vm.hooks.instruction, h)
+		added = true
+	}
+	if h, ok := hook.(MemoryHook); ok {
+		vm.hooks.memory = append(vm.hooks.memory, h)
+		added = true
+	}
+	if h, ok := hook.(InterruptHook); ok {
+		vm.hooks.interrupt = append(vm.hooks.interrupt, h)
+		added = true
+	}
+	if h, ok := hook.(PortHook); ok {
+		vm.hooks.port = append(vm.hooks.port, h)
+		added = true
+	}
+
+	if !added {
+		return errors.New("hook implements none of the hook interfaces")
+	}
+
+	return nil
+}
+
+// load reads size bytes of memory, 1, 2 or 4, for an instruction or an
+// interrupt and reports the access to memory hooks.
+func (vm *VM) load(addr uint16, size int) (uint32, error) {
+	var value uint32
+	var err error
+	switch size {
+	case 1:
+		var b byte
+		b, err = vm.memory.FetchByte(addr)
+		value = uint32(b)
+	case 2:
+		var w uint16
+		w, err = vm.memory.FetchWord(addr)
+		value = uint32(w)
+	default:
+		value, err = vm.memory.FetchDword(addr)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for _, h := range vm.hooks.memory {
+		h.OnMemoryRead(vm, addr, size, value)
+	}
+
+	return value, nil
+}
+
+// store writes the lower size bytes of the value to memory, like load.
+func (vm *VM) store(addr uint16, size int, value uint32) error {
+	var err error
+	switch size {
+	case 1:
+		err = vm.memory.StoreByte(addr, byte(value))
+	case 2:
+		err = vm.memory.StoreWord(addr, uint16(value))
+	default:
+		err = vm.memory.StoreDword(addr, value)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, h := range vm.hooks.memory {
+		h.OnMemoryWrite(vm, addr, size, value&(1<<(8*size)-1))
+	}
+
+	return nil
+}
+
+// swapped reports an atomic access to memory hooks: a read of the old value
+// and, if written is true, a write of the new one.
+func (vm *VM) swapped(addr uint16, old, value uint32, written bool) {
+	for _, h := range vm.hooks.memory {
+		h.OnMemoryRead(vm, addr, 4, old)
+		if written {
+			h.OnMemoryWrite(vm, addr, 4, value)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package vm
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/bartekpacia/toyvm/asm"
+)
+
+// recorder is a hook that records everything it observes.
+type recorder struct {
+	instructions []string
+	memory       []string
+	interrupts   []int
+	ports        []string
+}
+
+func (r *recorder) BeforeInstruction(vm *VM, pc uint16, opcode byte) {
+	r.instructions = append(r.instructions, fmt.Sprintf("before %#x %#02x", pc, opcode))
+}
+
+func (r *recorder) AfterInstruction(vm *VM, pc uint16, opcode byte) {
+	r.instructions = append(r.instructions, fmt.Sprintf("after %#x", pc))
+}
+
+func (r *recorder) OnMemoryRead(vm *VM, addr uint16, size int, value uint32) {
+	r.memory = append(r.memory, fmt.Sprintf("read %#x %d %#x", addr, size, value))
+}
+
+func (r *recorder) OnMemoryWrite(vm *VM, addr uint16, size int, value uint32) {
+	r.memory = append(r.memory, fmt.Sprintf("write %#x %d %#x", addr, size, value))
+}
+
+func (r *recorder) OnInterrupt(vm *VM, vector int) {
+	r.interrupts = append(r.interrupts, vector)
+}
+
+func (r *recorder) OnPortIO(vm *VM, port byte, value byte, out bool) {
+	r.ports = append(r.ports, fmt.Sprintf("%#x %#x %t", port, value, out))
+}
+
+func TestHooks(t *testing.T) {
+	program, err := asm.Assemble([]byte(`
+	vset r1, 0x1234
+	vstw r1, r1
+	vldb r2, r1
+	voutb 0x07, r2
+	vinb 0x07, r3
+	vint 5
+	times 0x40-($-$$) db 0
+	voff
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm := NewVM()
+	_ = vm.memory.StoreMany(0, program)
+	vm.creg[CregIntFirst+5] = 0x40
+
+	r := &recorder{}
+	err = vm.AddHook(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = vm.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantInstructions := []string{
+		"before 0x0 0x01", "after 0x0",
+		"before 0x6 0x08", "after 0x6",
+		"before 0x9 0x04", "after 0x9",
+		"before 0xc 0xf2", "after 0xc",
+		"before 0xf 0xf3", "after 0xf",
+		"before 0x12 0xf5", "after 0x12",
+		"before 0x40 0xff", "after 0x40",
+	}
+	if !slices.Equal(r.instructions, wantInstructions) {
+		t.Errorf("got instructions %q, want %q", r.instructions, wantInstructions)
+	}
+
+	wantMemory := []string{"write 0x1234 2 0x1234", "read 0x1234 1 0x34"}
+	if len(r.memory) != 2+contextSize || !slices.Equal(r.memory[:2], wantMemory) {
+		t.Errorf("got memory accesses %q, want %q and the saved context", r.memory, wantMemory)
+	}
+
+	wantPorts := []string{"0x7 0x34 true", "0x7 0x0 false"}
+	if !slices.Equal(r.ports, wantPorts) {
+		t.Errorf("got port I/O %q, want %q", r.ports, wantPorts)
+	}
+
+	if !slices.Equal(r.interrupts, []int{5}) {
+		t.Errorf("got interrupts %v, want [5]", r.interrupts)
+	}
+}
+
+func TestAddHookNotAHook(t *testing.T) {
+	vm := NewVM()
+	err := vm.AddHook(struct{}{})
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
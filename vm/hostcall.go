@@ -0,0 +1,62 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrHostCallInUse = errors.New("host call already in use")
+
+// HostCall is a function of the host program that guest code calls with
+// VHCALL. See RegisterHostCall for how it gets its arguments and returns its
+// results.
+type HostCall func(vm *VM) error
+
+// RegisterHostCall makes the function callable from guest code with VHCALL and
+// the ID. It fails if the ID is already in use.
+//
+// The function gets its arguments in R0 to R3, or in memory, with the address
+// in one of them. It returns its results in R0 and R1 and should leave other
+// registers unchanged. It can read and change the state of the machine with
+// Reg, SetReg and Memory.
+//
+// If the function returns an error, the guest gets exception
+// IntHostCallError, or IntMemoryError if the error wraps ErrInvalidAddress or
+// ErrReadOnly. Calling an ID that is not registered generates
+// IntGeneralError, like undefined opcodes.
+func (vm *VM) RegisterHostCall(id uint16, fn HostCall) error {
+	if _, ok := vm.hostCalls[id]; ok {
+		return fmt.Errorf("%w: %d", ErrHostCallInUse, id)
+	}
+
+	if vm.hostCalls == nil {
+		vm.hostCalls = make(map[uint16]HostCall)
+	}
+	vm.hostCalls[id] = fn
+	return nil
+}
+
+// hostCall calls the host function with the ID and raises an interrupt if it
+// fails.
+func (vm *VM) hostCall(id uint16) {
+	fn, ok := vm.hostCalls[id]
+	if !ok {
+		vm.interrupt(IntGeneralError)
+		return
+	}
+
+	err := fn(vm)
+	if err == nil {
+		return
+	}
+
+	if vm.debug {
+		fmt.Printf("debug: host call %d failed: %v\n", id, err)
+	}
+
+	if errors.Is(err, ErrInvalidAddress) || errors.Is(err, ErrReadOnly) {
+		vm.interrupt(IntMemoryError)
+	} else {
+		vm.interrupt(IntHostCallError)
+	}
+}
@@ -0,0 +1,63 @@
+package vm
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestHostCall(t *testing.T) {
+	vm := NewVM()
+	calls := map[uint16]HostCall{
+		1: func(vm *VM) error {
+			vm.SetReg(0, vm.Reg(0)+vm.Reg(1))
+			return nil
+		},
+		2: func(vm *VM) error {
+			return errors.New("service unavailable")
+		},
+		3: func(vm *VM) error {
+			_, err := vm.Memory().ReadAt(make([]byte, 4), int64(vm.Reg(0)))
+			return err
+		},
+	}
+	for id, fn := range calls {
+		err := vm.RegisterHostCall(id, fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := vm.RegisterHostCall(1, calls[1])
+	if !errors.Is(err, ErrHostCallInUse) {
+		t.Errorf("got %v registering a host call twice, want %v", err, ErrHostCallInUse)
+	}
+
+	testCases := []struct {
+		desc string
+		id   uint16
+		want []int
+	}{
+		{desc: "result in r0", id: 1},
+		{desc: "error", id: 2, want: []int{IntHostCallError}},
+		{desc: "memory error", id: 3, want: []int{IntMemoryError}},
+		{desc: "unregistered", id: 0x100, want: []int{IntGeneralError}},
+	}
+
+	for _, tc := range testCases {
+		vm.interruptQueue = nil
+		vm.SetReg(0, 0xfffe)
+		vm.SetReg(1, 2)
+
+		VHCALL(vm, []byte{byte(tc.id), byte(tc.id >> 8)})
+		if !slices.Equal(vm.interruptQueue, tc.want) {
+			t.Errorf("%s: got interrupts %v, want %v", tc.desc, vm.interruptQueue, tc.want)
+		}
+	}
+
+	vm.SetReg(0, 40)
+	VHCALL(vm, []byte{1, 0})
+	if vm.Reg(0) != 42 {
+		t.Errorf("got r0 = %d, want 42", vm.Reg(0))
+	}
+}
@@ -1,6 +1,10 @@
 package vm
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
 
 //lint:file-ignore ST1020 documentation for instructions is in the book
 
@@ -10,6 +14,7 @@ type opcode struct {
 	handler  InstructionHandler
 	length   int
 	mnemonic string
+	cycles   int // if more than 1
 }
 
 // region Data copying instructions
@@ -33,7 +38,7 @@ func VSET(vm *VM, args []byte) {
 // load
 func VLD(vm *VM, args []byte) {
 	addr := vm.reg[args[1]].value
-	data, err := vm.memory.FetchDword(uint16(addr))
+	data, err := vm.load(uint16(addr), 4)
 	if err != nil {
 		vm.interrupt(IntMemoryError)
 		return
@@ -45,7 +50,7 @@ func VLD(vm *VM, args []byte) {
 func VST(vm *VM, args []byte) {
 	rdst := &vm.reg[args[0]]
 	rsrc := &vm.reg[args[1]]
-	err := vm.memory.StoreDword(uint16(rdst.value), rsrc.value)
+	err := vm.store(uint16(rdst.value), 4, rsrc.value)
 	if err != nil {
 		vm.interrupt(IntMemoryError)
 	}
@@ -55,17 +60,83 @@ func VST(vm *VM, args []byte) {
 func VLDB(vm *VM, args []byte) {
 	rdst := &vm.reg[args[0]]
 	rsrc := &vm.reg[args[1]]
-	b, err := vm.memory.FetchByte(uint16(rsrc.value))
+	b, err := vm.load(uint16(rsrc.value), 1)
 	if err != nil {
 		vm.interrupt(IntMemoryError)
 		return
 	}
 
-	rdst.value = uint32(b)
+	rdst.value = b
 }
 
 // store byte
 func VSTB(vm *VM, args []byte) {
+	rdst := &vm.reg[args[0]]
+	rsrc := &vm.reg[args[1]]
+	err := vm.store(uint16(rdst.value), 1, rsrc.value)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+	}
+}
+
+// load word
+func VLDW(vm *VM, args []byte) {
+	loadWord(vm, args[0], uint16(vm.reg[args[1]].value), false)
+}
+
+// load signed word
+func VLDSW(vm *VM, args []byte) {
+	loadWord(vm, args[0], uint16(vm.reg[args[1]].value), true)
+}
+
+// store word
+func VSTW(vm *VM, args []byte) {
+	rsrc := &vm.reg[args[1]]
+	err := vm.store(uint16(vm.reg[args[0]].value), 2, rsrc.value)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+	}
+}
+
+// loadWord loads the 16-bit value at addr into the register, extending it to
+// 32 bits with zeros or, if signed is true, with its sign bit.
+func loadWord(vm *VM, reg byte, addr uint16, signed bool) {
+	value, err := vm.load(addr, 2)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+		return
+	}
+
+	if signed {
+		vm.reg[reg].value = uint32(int16(value))
+	} else {
+		vm.reg[reg].value = value
+	}
+}
+
+// load from stack
+func VLDSP(vm *VM, args []byte) {
+	addr := uint16(vm.sp.value) + imm16(args[1:])
+	data, err := vm.load(addr, 4)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+		return
+	}
+	vm.reg[args[0]].value = data
+}
+
+// store to stack
+func VSTSP(vm *VM, args []byte) {
+	addr := uint16(vm.sp.value) + imm16(args[1:])
+	err := vm.store(addr, 4, vm.reg[args[0]].value)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+	}
+}
+
+// imm16 decodes a little-endian 16-bit immediate.
+func imm16(args []byte) uint16 {
+	return uint16(args[0]) | uint16(args[1])<<8
 }
 
 // endregion
@@ -76,21 +147,41 @@ func VSTB(vm *VM, args []byte) {
 func VADD(vm *VM, args []byte) {
 	rdst := &vm.reg[args[0]]
 	rsrc := &vm.reg[args[1]]
-	rdst.value = rdst.value + rsrc.value
+	rdst.value = add(vm, rdst.value, rsrc.value, 0)
+}
+
+// add returns a + b + carry and sets the flags.
+func add(vm *VM, a, b, carry uint32) uint32 {
+	sum := uint64(a) + uint64(b) + uint64(carry)
+	signed := int64(int32(a)) + int64(int32(b)) + int64(carry)
+	vm.setFlags(uint32(sum), sum>>32 != 0, signed != int64(int32(sum)))
+	return uint32(sum)
 }
 
 // subtract
 func VSUB(vm *VM, args []byte) {
 	rdst := &vm.reg[args[0]]
 	rsrc := &vm.reg[args[1]]
-	rdst.value = rdst.value - rsrc.value
+	rdst.value = sub(vm, rdst.value, rsrc.value, 0)
+}
+
+// sub returns a - b - borrow and sets the flags.
+func sub(vm *VM, a, b, borrow uint32) uint32 {
+	diff := uint64(a) - uint64(b) - uint64(borrow)
+	signed := int64(int32(a)) - int64(int32(b)) - int64(borrow)
+	vm.setFlags(uint32(diff), diff>>32 != 0, signed != int64(int32(diff)))
+	return uint32(diff)
 }
 
 // multiply
 func VMUL(vm *VM, args []byte) {
 	rdst := &vm.reg[args[0]]
 	rsrc := &vm.reg[args[1]]
-	rdst.value = rdst.value * rsrc.value
+
+	product := uint64(rdst.value) * uint64(rsrc.value)
+	signed := int64(int32(rdst.value)) * int64(int32(rsrc.value))
+	rdst.value = uint32(product)
+	vm.setFlags(rdst.value, product>>32 != 0, signed != int64(int32(rdst.value)))
 }
 
 // divide
@@ -104,13 +195,21 @@ func VDIV(vm *VM, args []byte) {
 	}
 
 	rdst.value = rdst.value / rsrc.value
+	vm.setFlags(rdst.value, false, false)
 }
 
 // modulo
 func VMOD(vm *VM, args []byte) {
 	rdst := &vm.reg[args[0]]
 	rsrc := &vm.reg[args[1]]
+
+	if rsrc.value == 0 {
+		vm.interrupt(IntDivisionError)
+		return
+	}
+
 	rdst.value = rdst.value % rsrc.value
+	vm.setFlags(rdst.value, false, false)
 }
 
 // or
@@ -142,12 +241,22 @@ func VNOT(vm *VM, args []byte) {
 
 // shift left
 func VSHL(vm *VM, args []byte) {
-	// TODO: Implement VSHL
+	rdst := &vm.reg[args[0]]
+	count := vm.reg[args[1]].value & 31
+
+	carry := count != 0 && rdst.value>>(32-count)&1 != 0
+	rdst.value <<= count
+	vm.setFlags(rdst.value, carry, false)
 }
 
 // shift right
 func VSHR(vm *VM, args []byte) {
-	// TODO: Implement VSHR
+	rdst := &vm.reg[args[0]]
+	count := vm.reg[args[1]].value & 31
+
+	carry := count != 0 && rdst.value>>(count-1)&1 != 0
+	rdst.value >>= count
+	vm.setFlags(rdst.value, carry, false)
 }
 
 // endregion
@@ -159,17 +268,9 @@ func VCMP(vm *VM, args []byte) {
 	rdst := &vm.reg[args[0]]
 	rsrc := &vm.reg[args[1]]
 
-	result := int(rdst.value) - int(rsrc.value)
-	vm.fr &= 0xfffffffc
-
+	result := sub(vm, rdst.value, rsrc.value, 0)
 	if vm.debug {
-		fmt.Printf("VCMP: %v - %v = %v\n", rdst.value, rsrc.value, result)
-	}
-
-	if result == 0 {
-		vm.fr |= FlagZF
-	} else if result < 0 {
-		vm.fr |= FlagCF
+		fmt.Printf("VCMP: %v - %v = %v, fr: %04b\n", rdst.value, rsrc.value, result, vm.fr)
 	}
 }
 
@@ -199,7 +300,7 @@ func VJZ(vm *VM, args []byte) {
 		if vm.debug {
 			fmt.Println("==> VJZ: condition true, increased pc by", diff)
 		}
-		vm.pc.value = vm.pc.value + diff
+		vm.jump(args)
 	} else {
 		if vm.debug {
 			fmt.Println("==> VJZ: condition false, no-op")
@@ -214,41 +315,80 @@ func VJE(vm *VM, args []byte) {
 
 // jump if not zero
 func VJNZ(vm *VM, args []byte) {
-	// TODO: Implement VJNZ
+	if vm.fr&FlagZF == 0 {
+		vm.jump(args)
+	}
 }
 
+// jump if not equal
 func VJNE(vm *VM, args []byte) {
-	// TODO: implement VJNE
+	VJNZ(vm, args)
 }
 
 // jump if carry
 func VJC(vm *VM, args []byte) {
-	// TODO: Implement VJC
+	if vm.fr&FlagCF != 0 {
+		vm.jump(args)
+	}
 }
 
 // jump if below
 func VJB(vm *VM, args []byte) {
-	// TODO: Implement VJB
+	VJC(vm, args)
 }
 
 // jump if not carry
 func VJNC(vm *VM, args []byte) {
-	// TODO: Implement VJNC
+	if vm.fr&FlagCF == 0 {
+		vm.jump(args)
+	}
 }
 
 // jump if above or equal
 func VJAE(vm *VM, args []byte) {
-	// TODO: Implement VJAE
+	VJNC(vm, args)
 }
 
 // jump if below or equal
 func VJBE(vm *VM, args []byte) {
-	// TODO: Implement VJBE
+	if vm.fr&(FlagCF|FlagZF) != 0 {
+		vm.jump(args)
+	}
 }
 
 // jump if above
 func VJA(vm *VM, args []byte) {
-	// TODO: Implement VJA
+	if vm.fr&(FlagCF|FlagZF) == 0 {
+		vm.jump(args)
+	}
+}
+
+// jump if less (signed)
+func VJL(vm *VM, args []byte) {
+	if vm.signedLess() {
+		vm.jump(args)
+	}
+}
+
+// jump if greater or equal (signed)
+func VJGE(vm *VM, args []byte) {
+	if !vm.signedLess() {
+		vm.jump(args)
+	}
+}
+
+// jump if less or equal (signed)
+func VJLE(vm *VM, args []byte) {
+	if vm.fr&FlagZF != 0 || vm.signedLess() {
+		vm.jump(args)
+	}
+}
+
+// jump if greater (signed)
+func VJG(vm *VM, args []byte) {
+	if vm.fr&FlagZF == 0 && !vm.signedLess() {
+		vm.jump(args)
+	}
 }
 
 // endregion
@@ -257,12 +397,23 @@ func VJA(vm *VM, args []byte) {
 
 // push
 func VPUSH(vm *VM, args []byte) {
-	// TODO: Implement VPUSH
+	rsrc := &vm.reg[args[0]]
+	err := vm.push(rsrc.value)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+	}
 }
 
 // pop
 func VPOP(vm *VM, args []byte) {
-	// TODO: Implement VPOP
+	rdst := &vm.reg[args[0]]
+	value, err := vm.pop()
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+		return
+	}
+
+	rdst.value = value
 }
 
 // endregion
@@ -291,27 +442,330 @@ func VJMP(vm *VM, args []byte) {
 	// which means:
 	// VJMP 0x13 + 3 + 0x1a
 
-	vm.pc.value = vm.pc.value + diff
+	vm.jump(args)
 }
 
 // jump to address from register
 func VJMPR(vm *VM, args []byte) {
-	// TODO: Implement VJMPR
+	rsrc := &vm.reg[args[0]]
+	vm.pc.value = rsrc.value & 0xffff
 }
 
 // call
 func VCALL(vm *VM, args []byte) {
-	// TODO: Implement VCALL
+	err := vm.push(vm.pc.value)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+		return
+	}
+
+	vm.jump(args)
 }
 
 // call an address from register
 func VCALLR(vm *VM, args []byte) {
-	// TODO: Implement VCALLR
+	rsrc := &vm.reg[args[0]]
+	err := vm.push(vm.pc.value)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+		return
+	}
+
+	vm.pc.value = rsrc.value & 0xffff
 }
 
 // return
 func VRET(vm *VM, args []byte) {
-	// TODO: Implement VRET
+	addr, err := vm.pop()
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+		return
+	}
+
+	vm.pc.value = addr & 0xffff
+}
+
+// endregion
+
+// region Extended arithmetic instructions
+
+// signed divide
+func VIDIV(vm *VM, args []byte) {
+	rdst := &vm.reg[args[0]]
+	rsrc := &vm.reg[args[1]]
+
+	// The quotient of the lowest integer and -1 does not fit in 32 bits.
+	if rsrc.value == 0 || rdst.value == 1<<31 && rsrc.value == 0xffffffff {
+		vm.interrupt(IntDivisionError)
+		return
+	}
+
+	rdst.value = uint32(int32(rdst.value) / int32(rsrc.value))
+	vm.setFlags(rdst.value, false, false)
+}
+
+// signed modulo
+func VIMOD(vm *VM, args []byte) {
+	rdst := &vm.reg[args[0]]
+	rsrc := &vm.reg[args[1]]
+
+	if rsrc.value == 0 {
+		vm.interrupt(IntDivisionError)
+		return
+	}
+
+	rdst.value = uint32(int32(rdst.value) % int32(rsrc.value))
+	vm.setFlags(rdst.value, false, false)
+}
+
+// arithmetic shift right
+func VSAR(vm *VM, args []byte) {
+	rdst := &vm.reg[args[0]]
+	count := vm.reg[args[1]].value & 31
+
+	carry := count != 0 && rdst.value>>(count-1)&1 != 0
+	rdst.value = uint32(int32(rdst.value) >> count)
+	vm.setFlags(rdst.value, carry, false)
+}
+
+// rotate left
+func VROL(vm *VM, args []byte) {
+	rdst := &vm.reg[args[0]]
+	count := int(vm.reg[args[1]].value & 31)
+
+	rdst.value = bits.RotateLeft32(rdst.value, count)
+	vm.setFlags(rdst.value, count != 0 && rdst.value&1 != 0, false)
+}
+
+// rotate right
+func VROR(vm *VM, args []byte) {
+	rdst := &vm.reg[args[0]]
+	count := int(vm.reg[args[1]].value & 31)
+
+	rdst.value = bits.RotateLeft32(rdst.value, -count)
+	vm.setFlags(rdst.value, count != 0 && rdst.value>>31 != 0, false)
+}
+
+// add with carry
+func VADC(vm *VM, args []byte) {
+	rdst := &vm.reg[args[0]]
+	rsrc := &vm.reg[args[1]]
+	rdst.value = add(vm, rdst.value, rsrc.value, vm.carry())
+}
+
+// subtract with borrow
+func VSBB(vm *VM, args []byte) {
+	rdst := &vm.reg[args[0]]
+	rsrc := &vm.reg[args[1]]
+	rdst.value = sub(vm, rdst.value, rsrc.value, vm.carry())
+}
+
+// multiply long
+func VMULL(vm *VM, args []byte) {
+	rhigh := &vm.reg[args[0]]
+	rlow := &vm.reg[args[1]]
+
+	high, low := bits.Mul32(rlow.value, rhigh.value)
+	rhigh.value, rlow.value = high, low
+
+	vm.setFlags(high, high != 0, high != 0)
+	if low != 0 {
+		vm.fr &^= FlagZF
+	}
+}
+
+// endregion
+
+// region Floating-point instructions
+
+// The floating-point instructions treat registers as IEEE 754 single-precision
+// numbers. They are available only when ExtFloat is set in CregExtensions;
+// otherwise they raise IntGeneralError, like undefined opcodes. Invalid
+// operations, that is, ones with a NaN operand or result, raise
+// IntFloatError and leave the registers and flags unchanged.
+
+// floatArgs returns the operands of a floating-point instruction, or false
+// if the extension is disabled.
+func floatArgs(vm *VM, args []byte) (rdst *gpRegister, a, b float32, ok bool) {
+	if vm.creg[CregExtensions]&ExtFloat == 0 {
+		vm.interrupt(IntGeneralError)
+		return nil, 0, 0, false
+	}
+
+	rdst = &vm.reg[args[0]]
+	a = math.Float32frombits(rdst.value)
+	if len(args) > 1 {
+		b = math.Float32frombits(vm.reg[args[1]].value)
+	}
+
+	return rdst, a, b, true
+}
+
+func isNaN(f float32) bool {
+	return math.IsNaN(float64(f))
+}
+
+// floatResult stores the result of a floating-point operation in rdst, unless
+// the operation is invalid.
+func floatResult(vm *VM, rdst *gpRegister, a, b, result float32) {
+	if isNaN(a) || isNaN(b) || isNaN(result) {
+		vm.interrupt(IntFloatError)
+		return
+	}
+
+	rdst.value = math.Float32bits(result)
+	vm.setFlags(rdst.value&^(1<<31), false, false)
+	if result < 0 {
+		vm.fr |= FlagSF
+	}
+}
+
+// floating-point add
+func VFADD(vm *VM, args []byte) {
+	rdst, a, b, ok := floatArgs(vm, args)
+	if ok {
+		floatResult(vm, rdst, a, b, a+b)
+	}
+}
+
+// floating-point subtract
+func VFSUB(vm *VM, args []byte) {
+	rdst, a, b, ok := floatArgs(vm, args)
+	if ok {
+		floatResult(vm, rdst, a, b, a-b)
+	}
+}
+
+// floating-point multiply
+func VFMUL(vm *VM, args []byte) {
+	rdst, a, b, ok := floatArgs(vm, args)
+	if ok {
+		floatResult(vm, rdst, a, b, a*b)
+	}
+}
+
+// floating-point divide
+func VFDIV(vm *VM, args []byte) {
+	rdst, a, b, ok := floatArgs(vm, args)
+	if ok {
+		floatResult(vm, rdst, a, b, a/b)
+	}
+}
+
+// floating-point compare
+func VFCMP(vm *VM, args []byte) {
+	_, a, b, ok := floatArgs(vm, args)
+	if !ok {
+		return
+	}
+
+	if isNaN(a) || isNaN(b) {
+		vm.interrupt(IntFloatError)
+		return
+	}
+
+	// Less sets both CF and SF, so that both the unsigned and the signed
+	// conditional jumps work.
+	vm.fr &^= FlagZF | FlagCF | FlagSF | FlagOF
+	switch {
+	case a == b:
+		vm.fr |= FlagZF
+	case a < b:
+		vm.fr |= FlagCF | FlagSF
+	}
+}
+
+// convert integer to floating-point
+func VITOF(vm *VM, args []byte) {
+	rdst, _, _, ok := floatArgs(vm, args)
+	if ok {
+		rdst.value = math.Float32bits(float32(int32(rdst.value)))
+	}
+}
+
+// convert floating-point to integer
+func VFTOI(vm *VM, args []byte) {
+	rdst, a, _, ok := floatArgs(vm, args)
+	if !ok {
+		return
+	}
+
+	// The conversion is truncated towards zero. Values that do not fit,
+	// including infinities and NaN, are invalid.
+	if isNaN(a) || a < math.MinInt32 || a >= -math.MinInt32 {
+		vm.interrupt(IntFloatError)
+		return
+	}
+
+	rdst.value = uint32(int32(a))
+}
+
+// endregion
+
+// region Displacement addressing instructions
+
+// The displacement forms of the load and store instructions take the address
+// as a base register plus a 16-bit displacement, wrapping around at the end
+// of memory. Loads are encoded as rdst, rbase, imm16 and stores as rbase,
+// rsrc, imm16.
+
+// displaced returns the address in the base register plus the displacement.
+func displaced(vm *VM, base byte, disp []byte) uint16 {
+	return uint16(vm.reg[base].value) + imm16(disp)
+}
+
+// load with displacement
+func VLDO(vm *VM, args []byte) {
+	data, err := vm.load(displaced(vm, args[1], args[2:]), 4)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+		return
+	}
+	vm.reg[args[0]].value = data
+}
+
+// store with displacement
+func VSTO(vm *VM, args []byte) {
+	err := vm.store(displaced(vm, args[0], args[2:]), 4, vm.reg[args[1]].value)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+	}
+}
+
+// load byte with displacement
+func VLDBO(vm *VM, args []byte) {
+	b, err := vm.load(displaced(vm, args[1], args[2:]), 1)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+		return
+	}
+	vm.reg[args[0]].value = b
+}
+
+// store byte with displacement
+func VSTBO(vm *VM, args []byte) {
+	err := vm.store(displaced(vm, args[0], args[2:]), 1, vm.reg[args[1]].value)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+	}
+}
+
+// load word with displacement
+func VLDWO(vm *VM, args []byte) {
+	loadWord(vm, args[0], displaced(vm, args[1], args[2:]), false)
+}
+
+// load signed word with displacement
+func VLDSWO(vm *VM, args []byte) {
+	loadWord(vm, args[0], displaced(vm, args[1], args[2:]), true)
+}
+
+// store word with displacement
+func VSTWO(vm *VM, args []byte) {
+	err := vm.store(displaced(vm, args[0], args[2:]), 2, vm.reg[args[1]].value)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+	}
 }
 
 // endregion
@@ -320,36 +774,91 @@ func VRET(vm *VM, args []byte) {
 
 // control register load
 func VCRL(vm *VM, args []byte) {
-	// TODO: Implement VCRL
+	rsrc := &vm.reg[args[0]]
+	creg := int(args[1]) | int(args[2])<<8
+
+	if _, ok := vm.creg[creg]; !ok || creg == CregCoreID || creg == CregCoreCount {
+		vm.interrupt(IntGeneralError)
+		return
+	}
+
+	if creg == CregIPI {
+		vm.sendIPI(int(rsrc.value))
+		return
+	}
+
+	vm.creg[creg] = int(rsrc.value)
 }
 
 // control register store
 func VCRS(vm *VM, args []byte) {
-	// TODO: Implement VCRS
+	rdst := &vm.reg[args[0]]
+	creg := int(args[1]) | int(args[2])<<8
+
+	value, ok := vm.creg[creg]
+	if !ok {
+		vm.interrupt(IntGeneralError)
+		return
+	}
+
+	rdst.value = uint32(value)
 }
 
 // output byte
 func VOUTB(vm *VM, args []byte) {
-	// TODO: Dumb implementation. A proper one should be interrupt-based.
-	//  See also: https://github.com/gynvael/zrozumiec-programowanie/blob/master/007-Czesc_II-Rozdzial_3-Podstawy_architektury_komputerowe/vm_dev_con.py
-
-	rsrc := args[0]
-	value := vm.reg[rsrc].value & 0xff
-	toWrite := fmt.Sprintf("%c", value)
-	_, err := vm.Stdout.Write([]byte(toWrite))
-	if err != nil {
-		vm.interrupt(IntMemoryError)
+	rsrc := &vm.reg[args[0]]
+	vm.out(args[1], byte(rsrc.value))
+	for _, h := range vm.hooks.port {
+		h.OnPortIO(vm, args[1], byte(rsrc.value), true)
 	}
 }
 
 // input byte
 func VINB(vm *VM, args []byte) {
-	// TODO: Implement VINB
+	rdst := &vm.reg[args[0]]
+	value := vm.in(args[1])
+	rdst.value = uint32(value)
+	for _, h := range vm.hooks.port {
+		h.OnPortIO(vm, args[1], value, false)
+	}
 }
 
 // interrupt return
 func VIRET(vm *VM, args []byte) {
-	// TODO: Implement VIRET
+	err := vm.returnFromInterrupt()
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+	}
+}
+
+// software interrupt
+func VINT(vm *VM, args []byte) {
+	interrupt := int(args[0])
+	if interrupt > CregIntLast-CregIntFirst {
+		vm.interrupt(IntGeneralError)
+		return
+	}
+
+	// Software interrupts are delivered immediately, even if they are masked.
+	err := vm.enterInterrupt(interrupt)
+	if err != nil {
+		vm.halt(err)
+	}
+}
+
+// halt until an interrupt can be delivered
+func VHLT(vm *VM, args []byte) {
+	if !vm.canWake() {
+		vm.halt(ErrHaltedForever)
+		return
+	}
+
+	vm.halted = true
+}
+
+// host call
+func VHCALL(vm *VM, args []byte) {
+	vm.hostCall(imm16(args))
 }
 
 // crash
@@ -362,51 +871,41 @@ func VOFF(vm *VM, args []byte) {
 	vm.terminated = true
 }
 
-var opcodes = map[byte]opcode{
-	// data copying instructions
-	0x00: {handler: VMOV, length: 1 + 1, mnemonic: "MOV"},
-	0x01: {handler: VSET, length: 1 + 4, mnemonic: "SET"},
-	0x02: {handler: VLD, length: 1 + 1, mnemonic: "LD"},
-	0x03: {handler: VST, length: 1 + 1, mnemonic: "ST"},
-	0x04: {handler: VLDB, length: 1 + 1, mnemonic: "LDB"},
-	0x05: {handler: VSTB, length: 1 + 1, mnemonic: "STB"},
-	// arithmetic and logic instructions
-	0x10: {handler: VADD, length: 1 + 1, mnemonic: "ADD"},
-	0x11: {handler: VSUB, length: 1 + 1, mnemonic: "SUB"},
-	0x12: {handler: VMUL, length: 1 + 1, mnemonic: "MUL"},
-	0x13: {handler: VDIV, length: 1 + 1, mnemonic: "DIV"},
-	0x14: {handler: VMOD, length: 1 + 1, mnemonic: "MOD"},
-	0x15: {handler: VOR, length: 1 + 1, mnemonic: "OR"},
-	0x16: {handler: VAND, length: 1 + 1, mnemonic: "AND"},
-	0x17: {handler: VXOR, length: 1 + 1, mnemonic: "XOR"},
-	0x18: {handler: VNOT, length: 1, mnemonic: "NOT"},
-	0x19: {handler: VSHL, length: 1 + 1, mnemonic: "SHL"},
-	0x1A: {handler: VSHR, length: 1 + 1, mnemonic: "SHR"},
-	// comparison and conditional jumps instructions
-	0x20: {handler: VCMP, length: 1 + 1, mnemonic: "CMP"},
-	0x21: {handler: VJZ, length: 2, mnemonic: "JZ"},
-	0x22: {handler: VJNZ, length: 2, mnemonic: "JNZ"},
-	0x23: {handler: VJC, length: 2, mnemonic: "JC"},
-	0x24: {handler: VJNC, length: 2, mnemonic: "JNC"},
-	0x25: {handler: VJBE, length: 2, mnemonic: "JBE"},
-	0x26: {handler: VJA, length: 2, mnemonic: "JA"},
-	// stack manipulation instructions
-	0x30: {handler: VPUSH, length: 1, mnemonic: "PUSH"},
-	0x31: {handler: VPOP, length: 1, mnemonic: "POP"},
-	// unconditional jumps instructions
-	0x40: {handler: VJMP, length: 2, mnemonic: "JMP"},
-	0x41: {handler: VJMPR, length: 1, mnemonic: "JMPR"},
-	0x42: {handler: VCALL, length: 2, mnemonic: "CALL"},
-	0x43: {handler: VCALLR, length: 1, mnemonic: "CALLR"},
-	0x44: {handler: VRET, length: 0, mnemonic: "RET"},
-	// additional instructions
-	0xF0: {handler: VCRL, length: 1 + 2, mnemonic: "CRL"},
-	0xF1: {handler: VCRS, length: 1 + 2, mnemonic: "CRS"},
-	0xF2: {handler: VOUTB, length: 1 + 1, mnemonic: "OUTB"},
-	0xF3: {handler: VINB, length: 1 + 1, mnemonic: "INB"},
-	0xF4: {handler: VIRET, length: 0, mnemonic: "IRET"},
-	0xFE: {handler: VCRSH, length: 0, mnemonic: "CRSH"},
-	0xFF: {handler: VOFF, length: 0, mnemonic: "OFF"},
+// atomic compare and swap
+func VCAS(vm *VM, args []byte) {
+	rdst := &vm.reg[args[0]]
+	addr := vm.reg[args[1]].value
+	rsrc := &vm.reg[args[2]]
+
+	old, err := vm.memory.CompareAndSwapDword(uint16(addr), rdst.value, rsrc.value)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+		return
+	}
+
+	swapped := old == rdst.value
+	vm.swapped(uint16(addr), old, rsrc.value, swapped)
+	if swapped {
+		vm.fr |= FlagZF
+	} else {
+		vm.fr &^= FlagZF
+	}
+	rdst.value = old
+}
+
+// atomic exchange
+func VXCHG(vm *VM, args []byte) {
+	rdst := &vm.reg[args[0]]
+	addr := vm.reg[args[1]].value
+
+	old, err := vm.memory.SwapDword(uint16(addr), rdst.value)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+		return
+	}
+
+	vm.swapped(uint16(addr), old, rdst.value, true)
+	rdst.value = old
 }
 
 // endregion
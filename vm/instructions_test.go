@@ -1,6 +1,8 @@
 package vm
 
 import (
+	"math"
+	"slices"
 	"testing"
 )
 
@@ -18,6 +20,18 @@ func TestVmov(t *testing.T) {
 			args:   []byte{1, 4},
 			verify: func(vm *VM) bool { return vm.reg[1].value == 1 },
 		},
+		{
+			desc:   "copy value of PC, which is R15, to R0",
+			seed:   func(vm *VM) { vm.pc.value = 0x40 },
+			args:   []byte{0, 15},
+			verify: func(vm *VM) bool { return vm.reg[0].value == 0x40 },
+		},
+		{
+			desc:   "copy value of SP, which is R14, to R0",
+			seed:   func(vm *VM) {},
+			args:   []byte{0, 14},
+			verify: func(vm *VM) bool { return vm.reg[0].value == 0x10000 },
+		},
 	}
 
 	for _, tc := range testCases {
@@ -110,7 +124,68 @@ func TestVldb(t *testing.T) {
 	}
 }
 
-// TODO: TestVstb
+func TestVstb(t *testing.T) {
+	vm := NewVM()
+
+	vm.reg[2].value = 0x1234
+	vm.reg[1].value = 0x4142
+
+	VSTB(vm, []byte{2, 1})
+	if got := vm.memory.mem[0x1234]; got != 0x42 {
+		t.Errorf("got %x, want %x", got, 0x42)
+	}
+	if got := vm.memory.mem[0x1235]; got != 0 {
+		t.Errorf("got %x, want %x", got, 0)
+	}
+}
+
+func TestWordLoadStore(t *testing.T) {
+	vm := NewVM()
+	vm.reg[1].value = 0x1234
+	vm.reg[2].value = 0x1238
+	vm.reg[3].value = 0xabcd8001
+
+	VSTW(vm, []byte{1, 3})
+	if got := vm.memory.mem[0x1234:0x1237]; !slices.Equal(got, []byte{0x01, 0x80, 0x00}) {
+		t.Errorf("got memory % x, want 01 80 00", got)
+	}
+
+	VLDW(vm, []byte{4, 1})
+	VLDSW(vm, []byte{5, 1})
+	if vm.reg[4].value != 0x8001 || vm.reg[5].value != 0xffff8001 {
+		t.Errorf("got %#x and %#x, want 0x8001 and 0xffff8001", vm.reg[4].value, vm.reg[5].value)
+	}
+
+	vm.memory.mem[0x1238] = 0xff
+	vm.memory.mem[0x1239] = 0x7f
+	VLDSW(vm, []byte{5, 2})
+	if vm.reg[5].value != 0x7fff {
+		t.Errorf("got %#x, want 0x7fff", vm.reg[5].value)
+	}
+
+	vm.reg[1].value = 0xffff
+	VLDW(vm, []byte{4, 1})
+	if !slices.Equal(vm.interruptQueue, []int{IntMemoryError}) {
+		t.Errorf("got interrupts %v, want %v", vm.interruptQueue, []int{IntMemoryError})
+	}
+}
+
+func TestStackRelative(t *testing.T) {
+	vm := NewVM()
+	vm.sp.value = 0xff00
+	vm.reg[1].value = 0x12345678
+
+	VSTSP(vm, []byte{1, 0x08, 0x00})
+	got, _ := vm.memory.FetchDword(0xff08)
+	if got != 0x12345678 {
+		t.Errorf("got %#x at sp+8, want 0x12345678", got)
+	}
+
+	VLDSP(vm, []byte{2, 0x08, 0x00})
+	if vm.reg[2].value != 0x12345678 {
+		t.Errorf("got %#x, want 0x12345678", vm.reg[2].value)
+	}
+}
 
 // endregion
 
@@ -233,10 +308,149 @@ func TestVnot(t *testing.T) {
 	}
 }
 
+func TestVmodInterrupt(t *testing.T) {
+	vm := NewVM()
+	vm.reg[0].value = 40
+	vm.reg[1].value = 0
+
+	VMOD(vm, []byte{0, 1})
+	if len(vm.interruptQueue) != 1 || vm.interruptQueue[0] != IntDivisionError {
+		t.Errorf("got interrupts %v, want %v", vm.interruptQueue, []int{IntDivisionError})
+	}
+}
+
 func TestVshl(t *testing.T) {
+	vm := NewVM()
+	vm.reg[0].value = 0xc0000001
+	vm.reg[1].value = 1
+
+	VSHL(vm, []byte{0, 1})
+	if vm.reg[0].value != 0x80000002 || vm.fr != FlagCF|FlagSF {
+		t.Errorf("got %#x, fr %04b, want %#x, fr %04b", vm.reg[0].value, vm.fr, 0x80000002, FlagCF|FlagSF)
+	}
 }
 
 func TestVshr(t *testing.T) {
+	vm := NewVM()
+	vm.reg[0].value = 0x80000003
+	vm.reg[1].value = 33 // only the low 5 bits count
+
+	VSHR(vm, []byte{0, 1})
+	if vm.reg[0].value != 0x40000001 || vm.fr != FlagCF {
+		t.Errorf("got %#x, fr %04b, want %#x, fr %04b", vm.reg[0].value, vm.fr, 0x40000001, FlagCF)
+	}
+}
+
+// flagValues are the operands for which the flags are checked against Go
+// integer semantics: boundaries of the signed and unsigned ranges and some
+// small values of both signs.
+var flagValues = []uint32{
+	0, 1, 2, 5, 0x7ffffffe, 0x7fffffff, 0x80000000, 0x80000001,
+	0xfffffffb, 0xfffffffe, 0xffffffff, 0x10000, 0xffff0000,
+}
+
+// wantFlags returns the flags for the result, given the exact results of the
+// operation on unsigned and on signed operands.
+func wantFlags(result uint32, unsigned uint64, signed int64) uint32 {
+	var fr uint32
+	if result == 0 {
+		fr |= FlagZF
+	}
+	if unsigned != uint64(result) {
+		fr |= FlagCF
+	}
+	if int32(result) < 0 {
+		fr |= FlagSF
+	}
+	if signed != int64(int32(result)) {
+		fr |= FlagOF
+	}
+
+	return fr
+}
+
+func TestArithmeticFlags(t *testing.T) {
+	testCases := []struct {
+		mnemonic string
+		handler  func(*VM, []byte)
+		fr       uint32 // before the instruction
+		unsigned func(a, b uint64) uint64
+		signed   func(a, b int64) int64
+	}{
+		{
+			mnemonic: "VADD",
+			handler:  VADD,
+			unsigned: func(a, b uint64) uint64 { return a + b },
+			signed:   func(a, b int64) int64 { return a + b },
+		},
+		{
+			mnemonic: "VSUB",
+			handler:  VSUB,
+			unsigned: func(a, b uint64) uint64 { return a - b },
+			signed:   func(a, b int64) int64 { return a - b },
+		},
+		{
+			mnemonic: "VMUL",
+			handler:  VMUL,
+			unsigned: func(a, b uint64) uint64 { return a * b },
+			signed:   func(a, b int64) int64 { return a * b },
+		},
+		{
+			mnemonic: "VADD with carry set",
+			handler:  VADD,
+			fr:       FlagCF,
+			unsigned: func(a, b uint64) uint64 { return a + b },
+			signed:   func(a, b int64) int64 { return a + b },
+		},
+		{
+			mnemonic: "VADC",
+			handler:  VADC,
+			unsigned: func(a, b uint64) uint64 { return a + b },
+			signed:   func(a, b int64) int64 { return a + b },
+		},
+		{
+			mnemonic: "VADC with carry set",
+			handler:  VADC,
+			fr:       FlagCF,
+			unsigned: func(a, b uint64) uint64 { return a + b + 1 },
+			signed:   func(a, b int64) int64 { return a + b + 1 },
+		},
+		{
+			mnemonic: "VSBB",
+			handler:  VSBB,
+			unsigned: func(a, b uint64) uint64 { return a - b },
+			signed:   func(a, b int64) int64 { return a - b },
+		},
+		{
+			mnemonic: "VSBB with carry set",
+			handler:  VSBB,
+			fr:       FlagCF,
+			unsigned: func(a, b uint64) uint64 { return a - b - 1 },
+			signed:   func(a, b int64) int64 { return a - b - 1 },
+		},
+	}
+
+	for _, tc := range testCases {
+		for _, a := range flagValues {
+			for _, b := range flagValues {
+				vm := NewVM()
+				vm.fr = tc.fr
+				vm.reg[0].value = a
+				vm.reg[1].value = b
+
+				tc.handler(vm, []byte{0, 1})
+				result := vm.reg[0].value
+				unsigned := tc.unsigned(uint64(a), uint64(b))
+				signed := tc.signed(int64(int32(a)), int64(int32(b)))
+				if result != uint32(unsigned) {
+					t.Errorf("%s %#x, %#x: got %#x, want %#x", tc.mnemonic, a, b, result, uint32(unsigned))
+				}
+				if want := wantFlags(result, unsigned, signed); vm.fr != want {
+					t.Errorf("%s %#x, %#x: got fr %04b, want %04b", tc.mnemonic, a, b, vm.fr, want)
+				}
+			}
+		}
+	}
 }
 
 // endregion
@@ -268,13 +482,13 @@ func TestVcmp(t *testing.T) {
 			verify: func(vm *VM) bool { return vm.fr == FlagZF },
 		},
 		{
-			desc: "carry flag is set when the subtraction result is negative",
+			desc: "carry and sign flags are set when the subtraction borrows",
 			seed: func(vm *VM) {
 				vm.reg[0].value = 0
 				vm.reg[1].value = 5
 			},
 			args:   []byte{0, 1},
-			verify: func(vm *VM) bool { return vm.fr == FlagCF },
+			verify: func(vm *VM) bool { return vm.fr == FlagCF|FlagSF },
 		},
 	}
 
@@ -288,4 +502,456 @@ func TestVcmp(t *testing.T) {
 	}
 }
 
+func TestVjnz(t *testing.T) {
+	testCases := []struct {
+		fr   uint32
+		want uint32
+	}{
+		{fr: 0, want: 0x10 + 0x20},
+		{fr: FlagZF, want: 0x10},
+	}
+
+	for _, tc := range testCases {
+		vm := NewVM()
+		vm.pc.value = 0x10
+		vm.fr = tc.fr
+
+		VJNZ(vm, []byte{0x20, 0x00})
+		if vm.pc.value != tc.want {
+			t.Errorf("fr %b: got pc %#x, want %#x", tc.fr, vm.pc.value, tc.want)
+		}
+	}
+}
+
+func TestConditionalJumps(t *testing.T) {
+	testCases := []struct {
+		mnemonic string
+		handler  func(*VM, []byte)
+		cond     func(a, b uint32) bool
+	}{
+		{"VJZ", VJZ, func(a, b uint32) bool { return a == b }},
+		{"VJNZ", VJNZ, func(a, b uint32) bool { return a != b }},
+		{"VJB", VJB, func(a, b uint32) bool { return a < b }},
+		{"VJAE", VJAE, func(a, b uint32) bool { return a >= b }},
+		{"VJBE", VJBE, func(a, b uint32) bool { return a <= b }},
+		{"VJA", VJA, func(a, b uint32) bool { return a > b }},
+		{"VJL", VJL, func(a, b uint32) bool { return int32(a) < int32(b) }},
+		{"VJGE", VJGE, func(a, b uint32) bool { return int32(a) >= int32(b) }},
+		{"VJLE", VJLE, func(a, b uint32) bool { return int32(a) <= int32(b) }},
+		{"VJG", VJG, func(a, b uint32) bool { return int32(a) > int32(b) }},
+	}
+
+	for _, tc := range testCases {
+		for _, a := range flagValues {
+			for _, b := range flagValues {
+				vm := NewVM()
+				vm.pc.value = 0x10
+				vm.reg[0].value = a
+				vm.reg[1].value = b
+
+				VCMP(vm, []byte{0, 1})
+				tc.handler(vm, []byte{0x20, 0x00})
+				jumped := vm.pc.value == 0x30
+				if want := tc.cond(a, b); jumped != want {
+					t.Errorf("VCMP %#x, %#x; %s: got jump %t, want %t", a, b, tc.mnemonic, jumped, want)
+				}
+			}
+		}
+	}
+}
+
+// endregion
+
+// region Stack manipulation instructions
+func TestVpushVpop(t *testing.T) {
+	vm := NewVM()
+	vm.reg[1].value = 0x12345678
+
+	VPUSH(vm, []byte{1})
+	if vm.sp.value != 0x10000-4 {
+		t.Errorf("got sp %#x, want %#x", vm.sp.value, 0x10000-4)
+	}
+
+	VPOP(vm, []byte{2})
+	if vm.reg[2].value != 0x12345678 {
+		t.Errorf("got %#x, want %#x", vm.reg[2].value, 0x12345678)
+	}
+	if vm.sp.value != 0x10000 {
+		t.Errorf("got sp %#x, want %#x", vm.sp.value, 0x10000)
+	}
+}
+
+// endregion
+
+// region Unconditional jumps instructions
+func TestVjmpWrapsAround(t *testing.T) {
+	vm := NewVM()
+	vm.pc.value = 0x10
+
+	VJMP(vm, []byte{0xf0, 0xff})
+	if vm.pc.value != 0x0 {
+		t.Errorf("got pc %#x, want %#x", vm.pc.value, 0x0)
+	}
+}
+
+func TestVcallVret(t *testing.T) {
+	vm := NewVM()
+	vm.pc.value = 0x13
+
+	VCALL(vm, []byte{0x2d, 0x00})
+	if vm.pc.value != 0x40 {
+		t.Errorf("got pc %#x, want %#x", vm.pc.value, 0x40)
+	}
+
+	VRET(vm, nil)
+	if vm.pc.value != 0x13 {
+		t.Errorf("got pc %#x, want %#x", vm.pc.value, 0x13)
+	}
+
+	vm.reg[3].value = 0x1234
+	VCALLR(vm, []byte{3})
+	if vm.pc.value != 0x1234 {
+		t.Errorf("got pc %#x, want %#x", vm.pc.value, 0x1234)
+	}
+}
+
+// endregion
+
+// region Extended arithmetic instructions
+func TestExtendedArithmetic(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		handler func(*VM, []byte)
+		fr      uint32 // before the instruction
+		a, b    uint32
+		want    uint32 // in r0
+		wantFr  uint32
+	}{
+		{desc: "VIDIV rounds towards zero", handler: VIDIV, a: 0xfffffff9, b: 2, want: 0xfffffffd, wantFr: FlagSF},
+		{desc: "VIDIV of negative numbers", handler: VIDIV, a: 0xfffffff9, b: 0xfffffffe, want: 3},
+		{desc: "VIMOD takes the sign of the dividend", handler: VIMOD, a: 0xfffffff9, b: 2, want: 0xffffffff, wantFr: FlagSF},
+		{desc: "VIMOD of the lowest integer and -1", handler: VIMOD, a: 0x80000000, b: 0xffffffff, want: 0, wantFr: FlagZF},
+		{desc: "VSAR keeps the sign", handler: VSAR, a: 0x80000010, b: 4, want: 0xf8000001, wantFr: FlagSF},
+		{desc: "VSAR sets CF to the last bit shifted out", handler: VSAR, a: 0x18, b: 4, want: 1, wantFr: FlagCF},
+		{desc: "VSAR by 0 clears CF", handler: VSAR, fr: FlagCF, a: 0x18, b: 32, want: 0x18},
+		{desc: "VROL", handler: VROL, a: 0x80000001, b: 4, want: 0x00000018},
+		{desc: "VROL sets CF to the last bit rotated", handler: VROL, a: 0x80000001, b: 1, want: 3, wantFr: FlagCF},
+		{desc: "VROR", handler: VROR, a: 0x80000001, b: 4, want: 0x18000000},
+		{desc: "VROR sets CF to the last bit rotated", handler: VROR, a: 0x80000001, b: 1, want: 0xc0000000, wantFr: FlagCF | FlagSF},
+		{desc: "VADC carries into the next word", handler: VADC, fr: FlagCF, a: 0xffffffff, b: 0, want: 0, wantFr: FlagZF | FlagCF},
+		{desc: "VSBB borrows from the next word", handler: VSBB, fr: FlagCF, a: 0, b: 0, want: 0xffffffff, wantFr: FlagCF | FlagSF},
+	}
+
+	for _, tc := range testCases {
+		vm := NewVM()
+		vm.fr = tc.fr
+		vm.reg[0].value = tc.a
+		vm.reg[1].value = tc.b
+
+		tc.handler(vm, []byte{0, 1})
+		if vm.reg[0].value != tc.want || vm.fr != tc.wantFr {
+			t.Errorf("%s: got %#x, fr %04b, want %#x, fr %04b", tc.desc, vm.reg[0].value, vm.fr, tc.want, tc.wantFr)
+		}
+	}
+}
+
+func TestSignedDivision(t *testing.T) {
+	for _, a := range flagValues {
+		for _, b := range flagValues {
+			if b == 0 {
+				continue
+			}
+
+			x, y := int32(a), int32(b)
+			vm := NewVM()
+			vm.reg[0].value = a
+			vm.reg[1].value = b
+			VIDIV(vm, []byte{0, 1})
+			if x == math.MinInt32 && y == -1 {
+				if len(vm.interruptQueue) != 1 || vm.interruptQueue[0] != IntDivisionError {
+					t.Errorf("VIDIV %d, %d: got interrupts %v, want %v", x, y, vm.interruptQueue, []int{IntDivisionError})
+				}
+			} else if got := int32(vm.reg[0].value); got != x/y {
+				t.Errorf("VIDIV %d, %d: got %d, want %d", x, y, got, x/y)
+			}
+
+			vm = NewVM()
+			vm.reg[0].value = a
+			vm.reg[1].value = b
+			VIMOD(vm, []byte{0, 1})
+			if got := int32(vm.reg[0].value); got != x%y {
+				t.Errorf("VIMOD %d, %d: got %d, want %d", x, y, got, x%y)
+			}
+		}
+	}
+}
+
+func TestVidivInterrupt(t *testing.T) {
+	for _, handler := range []func(*VM, []byte){VIDIV, VIMOD} {
+		vm := NewVM()
+		vm.reg[0].value = 40
+		vm.reg[1].value = 0
+
+		handler(vm, []byte{0, 1})
+		if len(vm.interruptQueue) != 1 || vm.interruptQueue[0] != IntDivisionError {
+			t.Errorf("got interrupts %v, want %v", vm.interruptQueue, []int{IntDivisionError})
+		}
+	}
+}
+
+func TestVmull(t *testing.T) {
+	testCases := []struct {
+		a, b      uint32
+		high, low uint32
+		wantFr    uint32
+	}{
+		{a: 0xffffffff, b: 0xffffffff, high: 0xfffffffe, low: 1, wantFr: FlagCF | FlagSF | FlagOF},
+		{a: 0x10000, b: 0x10000, high: 1, low: 0, wantFr: FlagCF | FlagOF},
+		{a: 3, b: 5, high: 0, low: 15},
+		{a: 0, b: 5, high: 0, low: 0, wantFr: FlagZF},
+	}
+
+	for _, tc := range testCases {
+		vm := NewVM()
+		vm.reg[2].value = tc.a
+		vm.reg[3].value = tc.b
+
+		VMULL(vm, []byte{2, 3})
+		if vm.reg[2].value != tc.high || vm.reg[3].value != tc.low || vm.fr != tc.wantFr {
+			t.Errorf("%#x * %#x: got %#x:%#x, fr %04b, want %#x:%#x, fr %04b",
+				tc.a, tc.b, vm.reg[2].value, vm.reg[3].value, vm.fr, tc.high, tc.low, tc.wantFr)
+		}
+	}
+}
+
+// endregion
+
+// region Floating-point instructions
+func TestFloatingPoint(t *testing.T) {
+	f := math.Float32bits
+	inf := float32(math.Inf(1))
+
+	testCases := []struct {
+		desc          string
+		handler       func(*VM, []byte)
+		a, b          uint32
+		want          uint32 // in r0
+		wantFr        uint32
+		wantInterrupt int // -1 for none
+	}{
+		{desc: "VFADD", handler: VFADD, a: f(1.5), b: f(2.25), want: f(3.75), wantInterrupt: -1},
+		{desc: "VFSUB sets SF for a negative result", handler: VFSUB, a: f(1), b: f(2.5), want: f(-1.5), wantFr: FlagSF, wantInterrupt: -1},
+		{desc: "VFSUB sets ZF for zero", handler: VFSUB, a: f(2.5), b: f(2.5), want: f(0), wantFr: FlagZF, wantInterrupt: -1},
+		{desc: "VFMUL", handler: VFMUL, a: f(-3), b: f(0.5), want: f(-1.5), wantFr: FlagSF, wantInterrupt: -1},
+		{desc: "VFDIV", handler: VFDIV, a: f(1), b: f(8), want: f(0.125), wantInterrupt: -1},
+		{desc: "VFDIV by zero gives infinity", handler: VFDIV, a: f(1), b: f(0), want: f(inf), wantInterrupt: -1},
+		{desc: "VFDIV of zero by zero is invalid", handler: VFDIV, a: f(0), b: f(0), want: f(0), wantInterrupt: IntFloatError},
+		{desc: "VFSUB of infinities is invalid", handler: VFSUB, a: f(inf), b: f(inf), want: f(inf), wantInterrupt: IntFloatError},
+		{desc: "VFADD with NaN is invalid", handler: VFADD, a: 0x7fc00000, b: f(1), want: 0x7fc00000, wantInterrupt: IntFloatError},
+		{desc: "VFCMP less sets CF and SF", handler: VFCMP, a: f(-1), b: f(1), want: f(-1), wantFr: FlagCF | FlagSF, wantInterrupt: -1},
+		{desc: "VFCMP equal sets ZF", handler: VFCMP, a: f(0), b: 0x80000000, want: f(0), wantFr: FlagZF, wantInterrupt: -1},
+		{desc: "VFCMP greater", handler: VFCMP, a: f(inf), b: f(1), want: f(inf), wantInterrupt: -1},
+		{desc: "VFCMP with NaN is invalid", handler: VFCMP, a: f(1), b: 0x7fc00000, want: f(1), wantInterrupt: IntFloatError},
+		{desc: "VITOF", handler: VITOF, a: 0xfffffff9, want: f(-7), wantInterrupt: -1},
+		{desc: "VFTOI truncates towards zero", handler: VFTOI, a: f(-7.9), want: 0xfffffff9, wantInterrupt: -1},
+		{desc: "VFTOI of the lowest integer", handler: VFTOI, a: f(math.MinInt32), want: 0x80000000, wantInterrupt: -1},
+		{desc: "VFTOI out of range is invalid", handler: VFTOI, a: f(1 << 31), want: f(1 << 31), wantInterrupt: IntFloatError},
+		{desc: "VFTOI of infinity is invalid", handler: VFTOI, a: f(-inf), want: f(-inf), wantInterrupt: IntFloatError},
+	}
+
+	for _, tc := range testCases {
+		vm := NewVM()
+		vm.creg[CregExtensions] = ExtFloat
+		vm.reg[0].value = tc.a
+		vm.reg[1].value = tc.b
+
+		tc.handler(vm, []byte{0, 1})
+		if vm.reg[0].value != tc.want || vm.fr != tc.wantFr {
+			t.Errorf("%s: got %#x, fr %04b, want %#x, fr %04b", tc.desc, vm.reg[0].value, vm.fr, tc.want, tc.wantFr)
+		}
+
+		wantQueue := []int{}
+		if tc.wantInterrupt != -1 {
+			wantQueue = []int{tc.wantInterrupt}
+		}
+		if !slices.Equal(vm.interruptQueue, wantQueue) {
+			t.Errorf("%s: got interrupts %v, want %v", tc.desc, vm.interruptQueue, wantQueue)
+		}
+	}
+}
+
+func TestFloatingPointDisabled(t *testing.T) {
+	vm := NewVM()
+	vm.reg[0].value = math.Float32bits(1)
+
+	VFADD(vm, []byte{0, 0})
+	if vm.reg[0].value != math.Float32bits(1) || !slices.Equal(vm.interruptQueue, []int{IntGeneralError}) {
+		t.Errorf("got %#x and interrupts %v, want the register unchanged and %v", vm.reg[0].value, vm.interruptQueue, []int{IntGeneralError})
+	}
+}
+
+// endregion
+
+// region Displacement addressing instructions
+func TestDisplacement(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		handler func(*VM, []byte)
+		args    []byte
+		verify  func(*VM) bool
+	}{
+		{
+			desc:    "VLDO loads from base plus displacement",
+			handler: VLDO,
+			args:    []byte{0, 1, 0x10, 0x00},
+			verify:  func(vm *VM) bool { return vm.reg[0].value == 0x04030201 },
+		},
+		{
+			desc:    "VLDO with a negative displacement",
+			handler: VLDO,
+			args:    []byte{0, 2, 0xf0, 0xff},
+			verify:  func(vm *VM) bool { return vm.reg[0].value == 0x04030201 },
+		},
+		{
+			desc:    "VLDBO",
+			handler: VLDBO,
+			args:    []byte{0, 1, 0x13, 0x00},
+			verify:  func(vm *VM) bool { return vm.reg[0].value == 0x04 },
+		},
+		{
+			desc:    "VLDWO zero-extends",
+			handler: VLDWO,
+			args:    []byte{0, 1, 0x14, 0x00},
+			verify:  func(vm *VM) bool { return vm.reg[0].value == 0xfffe },
+		},
+		{
+			desc:    "VLDSWO sign-extends",
+			handler: VLDSWO,
+			args:    []byte{0, 1, 0x14, 0x00},
+			verify:  func(vm *VM) bool { return vm.reg[0].value == 0xfffffffe },
+		},
+		{
+			desc:    "VSTO stores at base plus displacement",
+			handler: VSTO,
+			args:    []byte{1, 3, 0x20, 0x00},
+			verify:  func(vm *VM) bool { got, _ := vm.memory.FetchDword(0x1020); return got == 0xa1b2c3d4 },
+		},
+		{
+			desc:    "VSTBO",
+			handler: VSTBO,
+			args:    []byte{1, 3, 0x20, 0x00},
+			verify:  func(vm *VM) bool { return vm.memory.mem[0x1020] == 0xd4 && vm.memory.mem[0x1021] == 0 },
+		},
+		{
+			desc:    "VSTWO",
+			handler: VSTWO,
+			args:    []byte{1, 3, 0x20, 0x00},
+			verify:  func(vm *VM) bool { got, _ := vm.memory.FetchDword(0x1020); return got == 0xc3d4 },
+		},
+		{
+			desc:    "memory error past the end of memory",
+			handler: VLDO,
+			args:    []byte{0, 4, 0xfe, 0x00},
+			verify:  func(vm *VM) bool { return slices.Equal(vm.interruptQueue, []int{IntMemoryError}) },
+		},
+	}
+
+	for _, tc := range testCases {
+		vm := NewVM()
+		copy(vm.memory.mem[0x1010:], []byte{0x01, 0x02, 0x03, 0x04, 0xfe, 0xff})
+		vm.reg[1].value = 0x1000
+		vm.reg[2].value = 0x1020
+		vm.reg[3].value = 0xa1b2c3d4
+		vm.reg[4].value = 0xff00
+
+		tc.handler(vm, tc.args)
+		if !tc.verify(vm) {
+			t.Errorf("test %#v failed", tc.desc)
+		}
+	}
+}
+
+// endregion
+
+// region Atomic instructions
+func TestVcas(t *testing.T) {
+	vm := NewVM()
+	vm.memory.StoreDword(0x100, 7)
+	vm.reg[1].value = 0x100
+	vm.reg[0].value = 7
+	vm.reg[2].value = 9
+
+	VCAS(vm, []byte{0, 1, 2})
+	got, _ := vm.memory.FetchDword(0x100)
+	if got != 9 || vm.reg[0].value != 7 || vm.fr&FlagZF == 0 {
+		t.Errorf("got memory %d, r0 %d, ZF %t; want 9, 7, true", got, vm.reg[0].value, vm.fr&FlagZF != 0)
+	}
+
+	vm.reg[2].value = 11
+	VCAS(vm, []byte{0, 1, 2})
+	got, _ = vm.memory.FetchDword(0x100)
+	if got != 9 || vm.reg[0].value != 9 || vm.fr&FlagZF != 0 {
+		t.Errorf("got memory %d, r0 %d, ZF %t; want 9, 9, false", got, vm.reg[0].value, vm.fr&FlagZF != 0)
+	}
+}
+
+func TestVxchg(t *testing.T) {
+	vm := NewVM()
+	vm.memory.StoreDword(0x100, 0x1234)
+	vm.reg[1].value = 0x100
+	vm.reg[0].value = 0x5678
+
+	VXCHG(vm, []byte{0, 1})
+	got, _ := vm.memory.FetchDword(0x100)
+	if got != 0x5678 || vm.reg[0].value != 0x1234 {
+		t.Errorf("got memory %#x, r0 %#x; want 0x5678, 0x1234", got, vm.reg[0].value)
+	}
+}
+
+// endregion
+
+// region Additional instructions
+func TestVint(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		seed   func(*VM)
+		args   []byte
+		verify func(*VM) bool
+	}{
+		{
+			desc: "enters the handler even if maskable interrupts are disabled",
+			seed: func(vm *VM) {
+				vm.creg[CregIntFirst+5] = 0x1234
+				vm.pc.value = 0x20
+			},
+			args: []byte{5},
+			verify: func(vm *VM) bool {
+				savedPc, _ := vm.memory.FetchDword(uint16(vm.sp.value + 3*4))
+				return vm.pc.value == 0x1234 && vm.creg[CregIntLevel] == 5 && savedPc == 0x20
+			},
+		},
+		{
+			desc:   "general error is raised for an invalid vector",
+			seed:   func(vm *VM) {},
+			args:   []byte{0x10},
+			verify: func(vm *VM) bool { return len(vm.interruptQueue) == 1 && vm.interruptQueue[0] == IntGeneralError },
+		},
+		{
+			desc:   "machine halts when a fault has no handler",
+			seed:   func(vm *VM) {},
+			args:   []byte{IntGeneralError},
+			verify: func(vm *VM) bool { return vm.terminated && vm.err != nil },
+		},
+	}
+
+	for _, tc := range testCases {
+		vm := NewVM()
+		tc.seed(vm)
+		VINT(vm, tc.args)
+		if !tc.verify(vm) {
+			t.Errorf("test %#v failed", tc.desc)
+		}
+	}
+}
+
 // endregion
@@ -0,0 +1,210 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDoubleFault is returned when the machine halts because an interrupt
+// could not be delivered and the double fault could not be handled either.
+var ErrDoubleFault = errors.New("double fault")
+
+// handlerNone is the value of an interrupt vector register that has no handler
+// installed.
+const handlerNone = 0xffffffff
+
+// contextSize is the number of dwords saved on the stack on interrupt entry:
+// all general-purpose registers, FR, CregIntContrl and CregIntLevel.
+const contextSize = 16 + 3
+
+func (vm *VM) interrupt(interrupt int) {
+	vm.interruptQueueMutex.Lock()
+	defer vm.interruptQueueMutex.Unlock()
+
+	vm.interruptQueue = append(vm.interruptQueue, interrupt)
+
+	select {
+	case vm.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func isMaskable(interrupt int) bool {
+	for _, maskableInterrupt := range MaskableInterrupts {
+		if interrupt == maskableInterrupt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deliverable reports whether the interrupt can preempt the code that is
+// currently running.
+//
+// Faults are always deliverable. IntWatchdog is non-maskable too, but it is
+// not a fault, so it is held back in the double fault handler. Maskable
+// interrupts are deliverable only if they are enabled in CregIntContrl and
+// have a higher priority (that is, a lower vector number) than the interrupt
+// being serviced.
+func (vm *VM) deliverable(interrupt int) bool {
+	if interrupt == IntWatchdog {
+		return vm.creg[CregIntLevel] != IntDoubleFault
+	}
+
+	if !isMaskable(interrupt) {
+		return true
+	}
+
+	if vm.creg[CregIntContrl]&IntContrlEnable == 0 {
+		return false
+	}
+
+	return interrupt < vm.creg[CregIntLevel]
+}
+
+// fetchPendingInterrupt returns a pending interrupt to be processed. Out of
+// the deliverable interrupts, the one with the highest priority is returned.
+// If no interrupts are available for processing, returns nil.
+func (vm *VM) fetchPendingInterrupt() *int {
+	vm.interruptQueueMutex.Lock()
+	defer vm.interruptQueueMutex.Unlock()
+
+	if len(vm.interruptQueue) == 0 {
+		return nil
+	}
+
+	best := -1
+	for i, interrupt := range vm.interruptQueue {
+		if !vm.deliverable(interrupt) {
+			continue
+		}
+
+		if best == -1 || interrupt < vm.interruptQueue[best] {
+			best = i
+		}
+	}
+
+	if best == -1 {
+		return nil
+	}
+
+	// pop element at index best
+	q := vm.interruptQueue
+	interrupt := q[best]
+	vm.interruptQueue = append(q[:best], q[best+1:]...)
+
+	return &interrupt
+}
+
+// processInterruptQueue processes an interrupt (if one is available).
+func (vm *VM) processInterruptQueue() error {
+	i := vm.fetchPendingInterrupt()
+
+	if i == nil {
+		return nil
+	}
+
+	return vm.enterInterrupt(*i)
+}
+
+// enterInterrupt saves the context on the stack and transfers control to the
+// handler of the interrupt.
+//
+// On entry, CregIntLevel is set to the vector of the interrupt and, unless
+// nesting is enabled in CregIntContrl, maskable interrupts are disabled. Both
+// are restored by VIRET.
+func (vm *VM) enterInterrupt(interrupt int) error {
+	if vm.creg[CregIntLevel] == IntDoubleFault && !isMaskable(interrupt) {
+		// Nothing sensible can be done when the double fault handler faults.
+		vm.crash()
+		return fmt.Errorf("%w: interrupt %d in double fault handler", ErrDoubleFault, interrupt)
+	}
+
+	handler := vm.creg[CregIntFirst+(interrupt&0xf)]
+	if handler == handlerNone {
+		if isMaskable(interrupt) || interrupt == IntWatchdog {
+			return nil
+		}
+
+		return vm.doubleFault(fmt.Errorf("no handler for interrupt %d", interrupt))
+	}
+
+	// Save context
+	tmpSp := vm.sp.value
+	registerValues := make([]uint32, 0, contextSize)
+	for _, register := range vm.reg {
+		registerValues = append(registerValues, register.value)
+	}
+	registerValues = append(registerValues, vm.fr)
+	registerValues = append(registerValues, uint32(vm.creg[CregIntContrl]))
+	registerValues = append(registerValues, uint32(vm.creg[CregIntLevel]))
+
+	for _, val := range registerValues {
+		tmpSp -= 4
+		err := vm.store(uint16(tmpSp), 4, val)
+		if err != nil {
+			return vm.doubleFault(fmt.Errorf("failed to store dword: %w", err))
+		}
+	}
+
+	vm.sp.value = tmpSp
+	vm.creg[CregIntLevel] = interrupt
+	if vm.creg[CregIntContrl]&IntContrlNested == 0 {
+		vm.creg[CregIntContrl] &^= IntContrlEnable
+	}
+	vm.pc.value = uint32(handler)
+	for _, h := range vm.hooks.interrupt {
+		h.OnInterrupt(vm, interrupt)
+	}
+	return nil
+}
+
+// doubleFault transfers control to the double fault handler. No context is
+// saved, so the handler cannot return with VIRET. If there is no double fault
+// handler, the machine crashes.
+func (vm *VM) doubleFault(cause error) error {
+	handler := vm.creg[CregIntFirst+IntDoubleFault]
+	if handler == handlerNone {
+		// Since there is no way to save the state, and therefore no way to
+		// recover, crash the machine.
+		vm.crash()
+		return fmt.Errorf("%w: %v", ErrDoubleFault, cause)
+	}
+
+	if vm.debug {
+		fmt.Printf("debug: double fault: %v\n", cause)
+	}
+
+	vm.creg[CregIntLevel] = IntDoubleFault
+	vm.creg[CregIntContrl] &^= IntContrlEnable
+	vm.pc.value = uint32(handler)
+	for _, h := range vm.hooks.interrupt {
+		h.OnInterrupt(vm, IntDoubleFault)
+	}
+	return nil
+}
+
+// returnFromInterrupt restores the context saved on the stack by
+// enterInterrupt.
+func (vm *VM) returnFromInterrupt() error {
+	tmpSp := vm.sp.value
+	registerValues := make([]uint32, contextSize)
+	for i := len(registerValues) - 1; i >= 0; i-- {
+		val, err := vm.load(uint16(tmpSp), 4)
+		if err != nil {
+			return fmt.Errorf("failed to fetch dword: %w", err)
+		}
+
+		registerValues[i] = val
+		tmpSp += 4
+	}
+
+	for i := range vm.reg {
+		vm.reg[i].value = registerValues[i]
+	}
+	vm.fr = registerValues[16]
+	vm.creg[CregIntContrl] = int(registerValues[17])
+	vm.creg[CregIntLevel] = int(registerValues[18])
+	return nil
+}
@@ -0,0 +1,194 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInterruptEntry(t *testing.T) {
+	vm := NewVM()
+	vm.creg[CregIntFirst+IntPit] = 0x1234
+	vm.creg[CregIntContrl] = IntContrlEnable
+	vm.pc.value = 0x40
+	vm.reg[3].value = 3
+	vm.fr = FlagZF
+
+	vm.interrupt(IntPit)
+	err := vm.processInterruptQueue()
+	if err != nil {
+		t.Fatalf("process interrupt queue: %v", err)
+	}
+
+	if vm.pc.value != 0x1234 {
+		t.Errorf("got pc %#x, want %#x", vm.pc.value, 0x1234)
+	}
+	if vm.sp.value != 0x10000-4*contextSize {
+		t.Errorf("got sp %#x, want %#x", vm.sp.value, 0x10000-4*contextSize)
+	}
+	if vm.creg[CregIntContrl]&IntContrlEnable != 0 {
+		t.Error("maskable interrupts are not disabled in the handler")
+	}
+	if vm.creg[CregIntLevel] != IntPit {
+		t.Errorf("got level %d, want %d", vm.creg[CregIntLevel], IntPit)
+	}
+
+	vm.reg[3].value = 0xdead
+	vm.fr = 0
+	VIRET(vm, nil)
+
+	if vm.pc.value != 0x40 {
+		t.Errorf("got pc %#x, want %#x", vm.pc.value, 0x40)
+	}
+	if vm.sp.value != 0x10000 {
+		t.Errorf("got sp %#x, want %#x", vm.sp.value, 0x10000)
+	}
+	if vm.reg[3].value != 3 {
+		t.Errorf("got r3 %#x, want %#x", vm.reg[3].value, 3)
+	}
+	if vm.fr != FlagZF {
+		t.Errorf("got fr %#x, want %#x", vm.fr, FlagZF)
+	}
+	if vm.creg[CregIntContrl] != IntContrlEnable {
+		t.Errorf("got int control %#x, want %#x", vm.creg[CregIntContrl], IntContrlEnable)
+	}
+	if vm.creg[CregIntLevel] != IntLevelNone {
+		t.Errorf("got level %d, want %d", vm.creg[CregIntLevel], IntLevelNone)
+	}
+}
+
+func TestFetchPendingInterrupt(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		contrl  int
+		level   int
+		pending []int
+		want    *int
+	}{
+		{
+			desc:    "maskable interrupt is not delivered when disabled",
+			contrl:  0,
+			level:   IntLevelNone,
+			pending: []int{IntPit},
+			want:    nil,
+		},
+		{
+			desc:    "fault is delivered when maskable interrupts are disabled",
+			contrl:  0,
+			level:   IntLevelNone,
+			pending: []int{IntPit, IntDivisionError},
+			want:    ptr(IntDivisionError),
+		},
+		{
+			desc:    "interrupt with the highest priority is delivered first",
+			contrl:  IntContrlEnable,
+			level:   IntLevelNone,
+			pending: []int{IntConsole, IntPit},
+			want:    ptr(IntPit),
+		},
+		{
+			desc:    "interrupt with a higher priority preempts the handler",
+			contrl:  IntContrlEnable | IntContrlNested,
+			level:   IntConsole,
+			pending: []int{IntPit},
+			want:    ptr(IntPit),
+		},
+		{
+			desc:    "interrupt with a lower priority does not preempt the handler",
+			contrl:  IntContrlEnable | IntContrlNested,
+			level:   IntPit,
+			pending: []int{IntConsole, IntPit},
+			want:    nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		vm := NewVM()
+		vm.creg[CregIntContrl] = tc.contrl
+		vm.creg[CregIntLevel] = tc.level
+		vm.interruptQueue = tc.pending
+
+		got := vm.fetchPendingInterrupt()
+		if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, deref(got), deref(tc.want))
+		}
+	}
+}
+
+func TestNestedInterrupt(t *testing.T) {
+	vm := NewVM()
+	vm.creg[CregIntFirst+IntPit] = 0x100
+	vm.creg[CregIntFirst+IntConsole] = 0x200
+	vm.creg[CregIntContrl] = IntContrlEnable | IntContrlNested
+
+	vm.interrupt(IntConsole)
+	if err := vm.processInterruptQueue(); err != nil {
+		t.Fatalf("process interrupt queue: %v", err)
+	}
+	if vm.creg[CregIntContrl]&IntContrlEnable == 0 {
+		t.Error("maskable interrupts are disabled in a nesting handler")
+	}
+
+	vm.interrupt(IntPit)
+	if err := vm.processInterruptQueue(); err != nil {
+		t.Fatalf("process interrupt queue: %v", err)
+	}
+	if vm.pc.value != 0x100 {
+		t.Errorf("got pc %#x, want %#x", vm.pc.value, 0x100)
+	}
+
+	VIRET(vm, nil)
+	if vm.pc.value != 0x200 || vm.creg[CregIntLevel] != IntConsole {
+		t.Errorf("got pc %#x and level %d, want %#x and %d", vm.pc.value, vm.creg[CregIntLevel], 0x200, IntConsole)
+	}
+}
+
+func TestDoubleFault(t *testing.T) {
+	vm := NewVM()
+	vm.memory = &Memory{mem: make([]byte, 16)}
+	vm.sp.value = 16
+	vm.creg[CregIntFirst+IntMemoryError] = 0x100
+	vm.creg[CregIntFirst+IntDoubleFault] = 0x300
+
+	vm.interrupt(IntMemoryError)
+	if err := vm.processInterruptQueue(); err != nil {
+		t.Fatalf("process interrupt queue: %v", err)
+	}
+	if vm.pc.value != 0x300 {
+		t.Errorf("got pc %#x, want %#x", vm.pc.value, 0x300)
+	}
+	if vm.creg[CregIntLevel] != IntDoubleFault {
+		t.Errorf("got level %d, want %d", vm.creg[CregIntLevel], IntDoubleFault)
+	}
+
+	// A fault in the double fault handler halts the machine.
+	vm.interrupt(IntGeneralError)
+	err := vm.processInterruptQueue()
+	if !errors.Is(err, ErrDoubleFault) {
+		t.Errorf("got %v, want %v", err, ErrDoubleFault)
+	}
+	if !vm.terminated {
+		t.Error("machine is not terminated")
+	}
+}
+
+func TestUnhandledFault(t *testing.T) {
+	vm := NewVM()
+
+	vm.interrupt(IntDivisionError)
+	err := vm.processInterruptQueue()
+	if !errors.Is(err, ErrDoubleFault) {
+		t.Errorf("got %v, want %v", err, ErrDoubleFault)
+	}
+}
+
+func ptr(v int) *int {
+	return &v
+}
+
+func deref(p *int) any {
+	if p == nil {
+		return nil
+	}
+
+	return *p
+}
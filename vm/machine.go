@@ -0,0 +1,216 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Scheduler decides how the cores of a Machine take turns.
+type Scheduler int
+
+const (
+	// RoundRobin runs the cores in one goroutine, a quantum of instructions
+	// each, in order. Runs are deterministic.
+	RoundRobin Scheduler = iota
+	// Parallel runs each core in its own goroutine.
+	Parallel
+)
+
+// Machine is a multi-core machine. Its cores share memory and devices, and
+// each has its own registers, including PC, SP and FR, interrupt vectors and
+// interrupt queue.
+//
+// Core 0 starts running right away. The other cores are parked until another
+// core sends them an IPI, by writing their number to CregIPI. A parked core
+// then starts at the address in CregCoreStart of the sending core, with all
+// other registers zeroed. A running core that receives an IPI gets IntIpi.
+//
+// Devices are attached to core 0, which ticks them and receives the
+// interrupts they raise on their own. Interrupts raised in response to VINB
+// and VOUTB go to the core that executed them.
+//
+// The machine stops when any of its cores powers off, crashes or fails.
+type Machine struct {
+	// Scheduler is the scheduler of the cores. It is RoundRobin by default.
+	Scheduler Scheduler
+	// Quantum is the number of instructions a core executes in its turn with
+	// the RoundRobin scheduler. It is 1 if not set.
+	Quantum int
+
+	cores  []*VM
+	memory sync.Mutex
+	io     sync.Mutex
+	done   chan struct{}
+	stop   sync.Once
+}
+
+// NewMachine creates a machine with the number of cores, which must be at
+// least 1.
+func NewMachine(cores int) (*Machine, error) {
+	if cores < 1 {
+		return nil, errors.New("a machine needs at least 1 core")
+	}
+
+	m := &Machine{done: make(chan struct{})}
+	for i := range cores {
+		core := NewVM()
+		if i != 0 {
+			core.memory = m.cores[0].memory
+			core.ports = m.cores[0].ports
+			core.sp.value = 0
+			core.parked.Store(true)
+		}
+
+		core.machine = m
+		core.wake = make(chan struct{}, 1)
+		core.creg[CregCoreID] = i
+		core.creg[CregCoreCount] = cores
+		m.cores = append(m.cores, core)
+	}
+
+	return m, nil
+}
+
+// Core returns the core with the number. Memory and devices are set up
+// through core 0.
+func (m *Machine) Core(i int) *VM {
+	return m.cores[i]
+}
+
+// Cores returns the number of cores.
+func (m *Machine) Cores() int {
+	return len(m.cores)
+}
+
+// sendIPI sends an inter-processor interrupt to the core.
+func (vm *VM) sendIPI(core int) {
+	if vm.machine == nil {
+		if core != 0 {
+			vm.interrupt(IntGeneralError)
+			return
+		}
+
+		vm.interrupt(IntIpi)
+		return
+	}
+
+	if core < 0 || core >= len(vm.machine.cores) {
+		vm.interrupt(IntGeneralError)
+		return
+	}
+
+	target := vm.machine.cores[core]
+	if !target.parked.CompareAndSwap(true, false) {
+		target.interrupt(IntIpi)
+		return
+	}
+
+	// The core does not run until it receives from wake.
+	target.pc.value = uint32(vm.creg[CregCoreStart])
+	select {
+	case target.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs the machine until it stops. Stdout and the debug setting of core 0
+// apply to all cores.
+func (m *Machine) Run() error {
+	for _, core := range m.cores {
+		core.Stdout = m.cores[0].Stdout
+		core.debug = m.cores[0].debug
+	}
+
+	if m.Scheduler == Parallel {
+		return m.runParallel()
+	}
+
+	quantum := max(m.Quantum, 1)
+	for {
+		idle := true
+		for i, core := range m.cores {
+			if core.parked.Load() {
+				continue
+			}
+
+			for range quantum {
+				err := core.runSingleStep()
+				if err != nil {
+					return fmt.Errorf("core %d: %w", i, err)
+				}
+
+				if core.terminated {
+					return nil
+				}
+			}
+
+			idle = idle && core.halted
+		}
+
+		// While all the cores are halted, only the devices, which are
+		// attached to core 0, can wake them.
+		if idle && m.cores[0].shouldSleep() {
+			m.cores[0].sleep(nil)
+		}
+	}
+}
+
+func (m *Machine) runParallel() error {
+	m.cores[0].memory.lock = &m.memory
+	for _, core := range m.cores {
+		core.io = &m.io
+	}
+
+	errs := make(chan error, len(m.cores))
+	var wg sync.WaitGroup
+	for i, core := range m.cores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer m.stop.Do(func() { close(m.done) })
+
+			err := m.runCore(core)
+			if err != nil {
+				errs <- fmt.Errorf("core %d: %w", i, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// runCore runs the core in the current goroutine until the machine stops.
+func (m *Machine) runCore(core *VM) error {
+	// Cores other than core 0 start parked, and are woken exactly once.
+	if core != m.cores[0] {
+		select {
+		case <-core.wake:
+		case <-m.done:
+			return nil
+		}
+	}
+
+	for {
+		select {
+		case <-m.done:
+			return nil
+		default:
+		}
+
+		err := core.runSingleStep()
+		if err != nil {
+			return err
+		}
+
+		if core.terminated {
+			return nil
+		}
+
+		if core.shouldSleep() {
+			core.sleep(m.done)
+		}
+	}
+}
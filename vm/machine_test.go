@@ -0,0 +1,142 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/bartekpacia/toyvm/asm"
+)
+
+// counterProgram starts core 1, then both cores increment the counter at
+// 0x1000 1000 times with VCAS. Core 1 sets the flag at 0x1004 when it is done,
+// and core 0 powers off the machine once the flag is set.
+const counterProgram = `
+%include "vm.inc"
+	vset r0, work
+	vcrl 0x123, r0
+	vset r0, 1
+	vcrl 0x122, r0
+
+work:
+	vset r1, 0x1000
+	vset r5, 1000
+	vset r6, 1
+	vset r7, 0
+.loop:
+	vld r0, r1
+.retry:
+	vmov r2, r0
+	vadd r2, r6
+	vcas r0, r1, r2
+	vjnz .retry
+	vsub r5, r6
+	vcmp r5, r7
+	vjnz .loop
+
+	vcrs 0x120, r0
+	vcmp r0, r7
+	vjz wait
+	vset r1, 0x1004
+	vxchg r6, r1
+	vjmp $
+
+wait:
+	vset r1, 0x1004
+.loop:
+	vld r0, r1
+	vcmp r0, r7
+	vjz .loop
+	voff
+`
+
+func setUpMachine(t *testing.T, cores int, src string) *Machine {
+	t.Helper()
+
+	m, err := NewMachine(cores)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program, err := asm.Assemble([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Core(0).memory.StoreMany(0, program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestMachineRun(t *testing.T) {
+	for _, scheduler := range []Scheduler{RoundRobin, Parallel} {
+		m := setUpMachine(t, 2, counterProgram)
+		m.Scheduler = scheduler
+		m.Quantum = 3
+
+		err := m.Run()
+		if err != nil {
+			t.Fatalf("scheduler %d: %v", scheduler, err)
+		}
+
+		got, _ := m.Core(0).memory.FetchDword(0x1000)
+		if got != 2000 {
+			t.Errorf("scheduler %d: got counter %d, want 2000", scheduler, got)
+		}
+	}
+}
+
+func TestMachineCoreRegisters(t *testing.T) {
+	m, err := NewMachine(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range m.Cores() {
+		core := m.Core(i)
+		if core.creg[CregCoreID] != i || core.creg[CregCoreCount] != 3 {
+			t.Errorf("core %d: got ID %d and count %d", i, core.creg[CregCoreID], core.creg[CregCoreCount])
+		}
+		if core.parked.Load() != (i != 0) {
+			t.Errorf("core %d: got parked %t", i, core.parked.Load())
+		}
+	}
+
+	VCRL(m.Core(0), []byte{0, CregCoreID & 0xff, CregCoreID >> 8})
+	queue := m.Core(0).interruptQueue
+	if len(queue) != 1 || queue[0] != IntGeneralError {
+		t.Errorf("got interrupt queue %v after writing CregCoreID, want [%d]", queue, IntGeneralError)
+	}
+}
+
+func TestMachineIPI(t *testing.T) {
+	m, err := NewMachine(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boot, core := m.Core(0), m.Core(1)
+	boot.creg[CregCoreStart] = 0x1234
+	boot.sendIPI(1)
+	if core.parked.Load() || core.pc.value != 0x1234 {
+		t.Errorf("got parked %t and pc %#x, want a running core at 0x1234", core.parked.Load(), core.pc.value)
+	}
+
+	boot.sendIPI(1)
+	if len(core.interruptQueue) != 1 || core.interruptQueue[0] != IntIpi {
+		t.Errorf("got interrupt queue %v, want [%d]", core.interruptQueue, IntIpi)
+	}
+
+	boot.sendIPI(2)
+	if len(boot.interruptQueue) != 1 || boot.interruptQueue[0] != IntGeneralError {
+		t.Errorf("got interrupt queue %v for a missing core, want [%d]", boot.interruptQueue, IntGeneralError)
+	}
+}
+
+func TestNewMachineNoCores(t *testing.T) {
+	_, err := NewMachine(0)
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,187 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrNoMailbox   = errors.New("no such mailbox")
+	ErrMailboxFull = errors.New("mailbox full")
+)
+
+// Mailbox ports. To send a message, the guest writes the ID of the
+// destination mailbox to PortMailboxTo and the message to PortMailboxOut,
+// then writes MailboxSend to PortMailboxControl. To receive one, it writes
+// MailboxReceive, then reads the message from PortMailboxIn and the ID of the
+// sender from PortMailboxFrom. Messages are 32-bit, little-endian.
+const (
+	PortMailboxTo      = 0x30
+	PortMailboxOut     = 0x31 // 0x31–0x34
+	PortMailboxControl = 0x35
+	PortMailboxFrom    = 0x36
+	PortMailboxID      = 0x37 // ID of this mailbox, read-only
+	PortMailboxIn      = 0x38 // 0x38–0x3b
+)
+
+// Commands written to PortMailboxControl.
+const (
+	MailboxSend    = 1
+	MailboxReceive = 2
+)
+
+// Status bits read from PortMailboxControl.
+const (
+	MailboxAvailable  = 1 << 0 // a message can be received
+	MailboxSendFailed = 1 << 1 // the last message could not be sent
+)
+
+// mailboxSize is the number of messages a mailbox can hold.
+const mailboxSize = 64
+
+// PostOffice delivers messages between the mailboxes of machines running in
+// separate goroutines.
+type PostOffice struct {
+	mu    sync.Mutex
+	boxes map[byte]*Mailbox
+}
+
+// NewPostOffice creates a post office with no mailboxes.
+func NewPostOffice() *PostOffice {
+	return &PostOffice{boxes: make(map[byte]*Mailbox)}
+}
+
+// NewMailbox creates a mailbox with the ID, to be attached to a machine.
+func (p *PostOffice) NewMailbox(id byte) (*Mailbox, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.boxes[id]; ok {
+		return nil, fmt.Errorf("mailbox %d already exists", id)
+	}
+
+	mb := &Mailbox{id: id, office: p}
+	p.boxes[id] = mb
+	return mb, nil
+}
+
+// Post puts the message from the sender into the mailbox to. It can be used
+// by the host to talk to the machines.
+func (p *PostOffice) Post(from, to byte, message uint32) error {
+	p.mu.Lock()
+	mb, ok := p.boxes[to]
+	p.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrNoMailbox, to)
+	}
+
+	return mb.put(mailboxMessage{from: from, value: message})
+}
+
+type mailboxMessage struct {
+	from  byte
+	value uint32
+}
+
+// Mailbox is a device that sends 32-bit messages to the mailboxes of other
+// machines. IntMailbox is raised when messages arrive.
+type Mailbox struct {
+	id     byte
+	office *PostOffice
+
+	to       byte
+	out      uint32
+	received mailboxMessage
+	status   byte // MailboxSendFailed
+
+	arrived atomic.Bool // since the last tick
+	mu      sync.Mutex
+	queue   []mailboxMessage
+}
+
+// ID returns the ID of the mailbox.
+func (mb *Mailbox) ID() byte {
+	return mb.id
+}
+
+func (mb *Mailbox) put(message mailboxMessage) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if len(mb.queue) == mailboxSize {
+		return fmt.Errorf("%w: %d", ErrMailboxFull, mb.id)
+	}
+
+	mb.queue = append(mb.queue, message)
+	mb.arrived.Store(true)
+	return nil
+}
+
+func (mb *Mailbox) Ports() []byte {
+	return []byte{
+		PortMailboxTo, PortMailboxOut, PortMailboxOut + 1, PortMailboxOut + 2, PortMailboxOut + 3,
+		PortMailboxControl, PortMailboxFrom, PortMailboxID,
+		PortMailboxIn, PortMailboxIn + 1, PortMailboxIn + 2, PortMailboxIn + 3,
+	}
+}
+
+func (mb *Mailbox) In(vm *VM, port byte) byte {
+	switch {
+	case port == PortMailboxTo:
+		return mb.to
+	case port >= PortMailboxOut && port < PortMailboxOut+4:
+		return byte(mb.out >> (8 * (port - PortMailboxOut)))
+	case port == PortMailboxControl:
+		mb.mu.Lock()
+		defer mb.mu.Unlock()
+
+		status := mb.status
+		if len(mb.queue) != 0 {
+			status |= MailboxAvailable
+		}
+
+		return status
+	case port == PortMailboxFrom:
+		return mb.received.from
+	case port == PortMailboxID:
+		return mb.id
+	default:
+		return byte(mb.received.value >> (8 * (port - PortMailboxIn)))
+	}
+}
+
+func (mb *Mailbox) Out(vm *VM, port byte, value byte) {
+	switch {
+	case port == PortMailboxTo:
+		mb.to = value
+	case port >= PortMailboxOut && port < PortMailboxOut+4:
+		shift := 8 * (port - PortMailboxOut)
+		mb.out = mb.out&^(0xff<<shift) | uint32(value)<<shift
+	case port == PortMailboxControl && value == MailboxSend:
+		mb.status = 0
+		err := mb.office.Post(mb.id, mb.to, mb.out)
+		if err != nil {
+			if vm.debug {
+				fmt.Printf("debug: mailbox %d failed to send: %v\n", mb.id, err)
+			}
+			mb.status = MailboxSendFailed
+		}
+	case port == PortMailboxControl && value == MailboxReceive:
+		mb.mu.Lock()
+		defer mb.mu.Unlock()
+
+		mb.received = mailboxMessage{}
+		if len(mb.queue) != 0 {
+			mb.received = mb.queue[0]
+			mb.queue = mb.queue[1:]
+		}
+	}
+}
+
+func (mb *Mailbox) Tick(vm *VM) {
+	if mb.arrived.Swap(false) {
+		vm.interrupt(IntMailbox)
+	}
+}
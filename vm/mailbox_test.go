@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMailbox(t *testing.T) {
+	office := NewPostOffice()
+	mbA, _ := office.NewMailbox(1)
+	mbB, _ := office.NewMailbox(2)
+	if _, err := office.NewMailbox(2); err == nil {
+		t.Error("created a mailbox with a duplicate ID")
+	}
+
+	vmA, vmB := NewVM(), NewVM()
+	_ = vmA.AttachDevice(mbA)
+	_ = vmB.AttachDevice(mbB)
+
+	mbA.Out(vmA, PortMailboxTo, 2)
+	for i, b := range []byte{0x78, 0x56, 0x34, 0x12} {
+		mbA.Out(vmA, PortMailboxOut+byte(i), b)
+	}
+	mbA.Out(vmA, PortMailboxControl, MailboxSend)
+	if status := mbA.In(vmA, PortMailboxControl); status != 0 {
+		t.Errorf("got sender status %#x, want 0", status)
+	}
+
+	mbB.Tick(vmB)
+	if len(vmB.interruptQueue) != 1 || vmB.interruptQueue[0] != IntMailbox {
+		t.Errorf("got interrupts %v, want %v", vmB.interruptQueue, []int{IntMailbox})
+	}
+	if status := mbB.In(vmB, PortMailboxControl); status != MailboxAvailable {
+		t.Errorf("got status %#x, want %#x", status, MailboxAvailable)
+	}
+
+	mbB.Out(vmB, PortMailboxControl, MailboxReceive)
+	var message uint32
+	for i := range 4 {
+		message |= uint32(mbB.In(vmB, PortMailboxIn+byte(i))) << (8 * i)
+	}
+	if message != 0x12345678 {
+		t.Errorf("got message %#x, want 0x12345678", message)
+	}
+	if from := mbB.In(vmB, PortMailboxFrom); from != 1 {
+		t.Errorf("got sender %d, want 1", from)
+	}
+	if status := mbB.In(vmB, PortMailboxControl); status != 0 {
+		t.Errorf("got status %#x after receiving, want 0", status)
+	}
+
+	mbA.Out(vmA, PortMailboxTo, 3)
+	mbA.Out(vmA, PortMailboxControl, MailboxSend)
+	if status := mbA.In(vmA, PortMailboxControl); status != MailboxSendFailed {
+		t.Errorf("got status %#x after sending to a missing mailbox, want %#x", status, MailboxSendFailed)
+	}
+}
+
+func TestMailboxFull(t *testing.T) {
+	office := NewPostOffice()
+	_, _ = office.NewMailbox(1)
+
+	var err error
+	for i := 0; err == nil; i++ {
+		err = office.Post(0, 1, uint32(i))
+	}
+	if !errors.Is(err, ErrMailboxFull) {
+		t.Errorf("got %v, want %v", err, ErrMailboxFull)
+	}
+}
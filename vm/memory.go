@@ -1,76 +1,298 @@
 package vm
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"sync"
 )
 
-var ErrInvalidAddress = errors.New("invalid address")
+var (
+	ErrInvalidAddress = errors.New("invalid address")
+	ErrReadOnly       = errors.New("read-only memory")
+)
 
 // Memory represents little-endian RAM.
 type Memory struct {
-	mem []byte
+	mem      []byte
+	readOnly []span          // ranges the guest cannot write to
+	shared   []sharedMapping // ranges backed by shared memory
+
+	// lock, if not nil, is held during every access. It is set when the
+	// memory is accessed by several cores running in parallel.
+	lock sync.Locker
+}
+
+// span is a range of addresses from start (inclusive) to end (exclusive).
+type span struct {
+	start, end int
+}
+
+// sharedMapping is a range of addresses backed by shared memory.
+type sharedMapping struct {
+	span
+	memory *SharedMemory
+}
+
+// Protect makes the range of memory read-only.
+func (m *Memory) Protect(addr uint16, size int) {
+	m.readOnly = append(m.readOnly, span{start: int(addr), end: int(addr) + size})
+}
+
+// AddressError records a failed access to memory. It wraps ErrInvalidAddress
+// and, for writes to read-only memory, ErrReadOnly as well.
+type AddressError struct {
+	Op   string // "read" or "write"
+	Addr int
+	Size int
+	Err  error
+}
+
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("%s of %d bytes at %#04x: %v", e.Op, e.Size, e.Addr, e.Err)
+}
+
+func (e *AddressError) Unwrap() error {
+	return e.Err
+}
+
+// checkReadable returns an error if the range is not in memory.
+func (m *Memory) checkReadable(addr int, size int) error {
+	if addr < 0 || addr+size > len(m.mem) {
+		return &AddressError{Op: "read", Addr: addr, Size: size, Err: ErrInvalidAddress}
+	}
+
+	return nil
+}
+
+// checkWritable returns an error if the range is not in memory or any byte of
+// it is read-only.
+func (m *Memory) checkWritable(addr int, size int) error {
+	if addr < 0 || addr+size > len(m.mem) {
+		return &AddressError{Op: "write", Addr: addr, Size: size, Err: ErrInvalidAddress}
+	}
+
+	for _, s := range m.readOnly {
+		if addr < s.end && addr+size > s.start {
+			err := fmt.Errorf("%w: %w", ErrInvalidAddress, ErrReadOnly)
+			return &AddressError{Op: "write", Addr: addr, Size: size, Err: err}
+		}
+	}
+
+	return nil
 }
 
 func (m *Memory) StoreByte(addr uint16, value byte) error {
-	if int(addr) >= len(m.mem) {
-		return fmt.Errorf("%w: %d", ErrInvalidAddress, addr)
+	if err := m.checkWritable(int(addr), 1); err != nil {
+		return err
 	}
 
-	m.mem[int(addr)] = value
+	m.write(int(addr), []byte{value})
 	return nil
 }
 
 func (m *Memory) FetchByte(addr uint16) (byte, error) {
-	if int(addr) >= len(m.mem) {
-		return 0, fmt.Errorf("%w: %d", ErrInvalidAddress, addr)
+	if err := m.checkReadable(int(addr), 1); err != nil {
+		return 0, err
 	}
 
-	return m.mem[addr], nil
+	var value [1]byte
+	m.read(int(addr), value[:])
+	return value[0], nil
 }
 
 func (m *Memory) FetchDword(addr uint16) (uint32, error) {
-	if int(addr)+3 >= len(m.mem) {
-		return 0, fmt.Errorf("%w: %d", ErrInvalidAddress, addr)
+	if err := m.checkReadable(int(addr), 4); err != nil {
+		return 0, err
 	}
 
-	var value uint32
-	value = uint32(m.mem[addr])
-	value = value | uint32(m.mem[addr+1])<<8
-	value = value | uint32(m.mem[addr+2])<<16
-	value = value | uint32(m.mem[addr+3])<<24
-	return value, nil
+	var value [4]byte
+	m.read(int(addr), value[:])
+	return binary.LittleEndian.Uint32(value[:]), nil
 }
 
 func (m *Memory) StoreDword(addr uint16, value uint32) error {
-	if int(addr)+3 >= len(m.mem) {
-		return fmt.Errorf("%w: %d", ErrInvalidAddress, addr)
+	if err := m.checkWritable(int(addr), 4); err != nil {
+		return err
 	}
 
-	m.mem[int(addr)] = byte(value)
-	m.mem[int(addr)+1] = byte(value >> 8)
-	m.mem[int(addr)+2] = byte(value >> 16)
-	m.mem[int(addr)+3] = byte(value >> 24)
+	m.write(int(addr), binary.LittleEndian.AppendUint32(make([]byte, 0, 4), value))
+	return nil
+}
 
+func (m *Memory) FetchWord(addr uint16) (uint16, error) {
+	if err := m.checkReadable(int(addr), 2); err != nil {
+		return 0, err
+	}
+
+	var value [2]byte
+	m.read(int(addr), value[:])
+	return binary.LittleEndian.Uint16(value[:]), nil
+}
+
+func (m *Memory) StoreWord(addr uint16, value uint16) error {
+	if err := m.checkWritable(int(addr), 2); err != nil {
+		return err
+	}
+
+	m.write(int(addr), binary.LittleEndian.AppendUint16(make([]byte, 0, 2), value))
 	return nil
 }
 
 func (m *Memory) FetchMany(addr uint16, size int) ([]byte, error) {
-	if int(addr)+size >= len(m.mem) {
-		return nil, fmt.Errorf("%w: %d", ErrInvalidAddress, addr)
+	if err := m.checkReadable(int(addr), size); err != nil {
+		return nil, err
 	}
 
-	return m.mem[addr : int(addr)+size], nil
+	if len(m.shared) == 0 && m.lock == nil {
+		return m.mem[addr : int(addr)+size], nil
+	}
+
+	data := make([]byte, size)
+	m.read(int(addr), data)
+	return data, nil
 }
 
 func (m *Memory) StoreMany(addr uint16, data []byte) error {
-	if int(addr)+len(data) >= len(m.mem) {
-		return fmt.Errorf("%w: %d", ErrInvalidAddress, addr)
+	if err := m.checkWritable(int(addr), len(data)); err != nil {
+		return err
 	}
 
-	for i := 0; i < len(data); i++ {
-		m.mem[int(addr)+i] = data[i]
+	m.write(int(addr), data)
+	return nil
+}
+
+// ReadAt copies memory at the offset to p. It implements io.ReaderAt. If the
+// range is not entirely in memory, nothing is read and the error is an
+// *AddressError.
+func (m *Memory) ReadAt(p []byte, off int64) (int, error) {
+	if err := m.checkReadable(int(off), len(p)); err != nil {
+		return 0, err
 	}
 
-	return nil
+	m.read(int(off), p)
+	return len(p), nil
+}
+
+// WriteAt copies p to memory at the offset. It implements io.WriterAt. If the
+// range is not entirely in memory or any of it is read-only, nothing is
+// written and the error is an *AddressError.
+func (m *Memory) WriteAt(p []byte, off int64) (int, error) {
+	if err := m.checkWritable(int(off), len(p)); err != nil {
+		return 0, err
+	}
+
+	m.write(int(off), p)
+	return len(p), nil
+}
+
+// SwapDword atomically stores the value at addr and returns the previous
+// value.
+func (m *Memory) SwapDword(addr uint16, value uint32) (uint32, error) {
+	return m.updateDword(addr, func(uint32) uint32 { return value })
+}
+
+// CompareAndSwapDword atomically stores the value at addr if the previous value
+// is old. It returns the previous value.
+func (m *Memory) CompareAndSwapDword(addr uint16, old, value uint32) (uint32, error) {
+	return m.updateDword(addr, func(current uint32) uint32 {
+		if current == old {
+			return value
+		}
+
+		return current
+	})
+}
+
+// updateDword atomically replaces the dword at addr with the result of f and
+// returns the previous value.
+func (m *Memory) updateDword(addr uint16, f func(uint32) uint32) (uint32, error) {
+	if err := m.checkWritable(int(addr), 4); err != nil {
+		return 0, err
+	}
+
+	if m.lock != nil {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+	}
+
+	data := m.mem[addr:]
+	n, mapping := m.segment(int(addr), 4)
+	if n != 4 {
+		return 0, &AddressError{Op: "write", Addr: int(addr), Size: 4, Err: fmt.Errorf("%w: dword crosses the boundary of shared memory", ErrInvalidAddress)}
+	}
+	if mapping != nil {
+		mapping.memory.mu.Lock()
+		defer mapping.memory.mu.Unlock()
+		data = mapping.memory.data[int(addr)-mapping.start:]
+	}
+
+	old := binary.LittleEndian.Uint32(data)
+	binary.LittleEndian.PutUint32(data, f(old))
+	return old, nil
+}
+
+// read copies memory at addr to buf. Parts of the range backed by shared
+// memory are read while holding its lock.
+func (m *Memory) read(addr int, buf []byte) {
+	if m.lock != nil {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+	}
+
+	for len(buf) > 0 {
+		n, mapping := m.segment(addr, len(buf))
+		if mapping == nil {
+			copy(buf[:n], m.mem[addr:])
+		} else {
+			mapping.memory.mu.Lock()
+			copy(buf[:n], mapping.memory.data[addr-mapping.start:])
+			mapping.memory.mu.Unlock()
+		}
+
+		addr += n
+		buf = buf[n:]
+	}
+}
+
+// write copies data to memory at addr. Parts of the range backed by shared
+// memory are written while holding its lock.
+func (m *Memory) write(addr int, data []byte) {
+	if m.lock != nil {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+	}
+
+	for len(data) > 0 {
+		n, mapping := m.segment(addr, len(data))
+		if mapping == nil {
+			copy(m.mem[addr:], data[:n])
+		} else {
+			mapping.memory.mu.Lock()
+			copy(mapping.memory.data[addr-mapping.start:], data[:n])
+			mapping.memory.mu.Unlock()
+		}
+
+		addr += n
+		data = data[n:]
+	}
+}
+
+// segment returns the length of the longest part of the range at addr that is
+// either backed by a single shared memory mapping, which is returned, or not
+// shared at all.
+func (m *Memory) segment(addr, size int) (int, *sharedMapping) {
+	end := addr + size
+	for i := range m.shared {
+		mapping := &m.shared[i]
+		if addr >= mapping.start && addr < mapping.end {
+			return min(end, mapping.end) - addr, mapping
+		}
+
+		if mapping.start > addr && mapping.start < end {
+			end = mapping.start
+		}
+	}
+
+	return end - addr, nil
 }
@@ -107,3 +107,91 @@ func TestStoreDword(t *testing.T) {
 		}
 	}
 }
+
+func TestProtect(t *testing.T) {
+	memory := &Memory{mem: make([]byte, 8)}
+	memory.Protect(4, 2)
+
+	testCases := []struct {
+		store   func() error
+		wantErr error
+	}{
+		{store: func() error { return memory.StoreByte(3, 1) }, wantErr: nil},
+		{store: func() error { return memory.StoreByte(4, 1) }, wantErr: ErrReadOnly},
+		{store: func() error { return memory.StoreDword(0, 1) }, wantErr: nil},
+		{store: func() error { return memory.StoreDword(2, 1) }, wantErr: ErrReadOnly},
+		{store: func() error { return memory.StoreMany(5, []byte{1}) }, wantErr: ErrReadOnly},
+		{store: func() error { return memory.StoreMany(6, []byte{1}) }, wantErr: nil},
+	}
+
+	for _, tc := range testCases {
+		err := tc.store()
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("got %#v, want %#v", err, tc.wantErr)
+		}
+	}
+
+	if memory.mem[4] != 0 || memory.mem[5] != 0 {
+		t.Errorf("read-only memory was modified: %v", memory.mem)
+	}
+}
+
+func TestReadAtWriteAt(t *testing.T) {
+	memory := &Memory{mem: make([]byte, 8)}
+	memory.Protect(6, 2)
+
+	n, err := memory.WriteAt([]byte{1, 2, 3}, 3)
+	if n != 3 || err != nil {
+		t.Fatalf("got %d, %v", n, err)
+	}
+
+	buf := make([]byte, 4)
+	n, err = memory.ReadAt(buf, 2)
+	if n != 4 || err != nil || !bytes.Equal(buf, []byte{0, 1, 2, 3}) {
+		t.Errorf("got %d, %v and % x, want 4 bytes 00 01 02 03", n, err, buf)
+	}
+
+	testCases := []struct {
+		desc    string
+		access  func() (int, error)
+		want    AddressError
+		wantErr error
+	}{
+		{
+			desc:    "read past the end",
+			access:  func() (int, error) { return memory.ReadAt(buf, 6) },
+			want:    AddressError{Op: "read", Addr: 6, Size: 4},
+			wantErr: ErrInvalidAddress,
+		},
+		{
+			desc:    "read at a negative offset",
+			access:  func() (int, error) { return memory.ReadAt(buf, -1) },
+			want:    AddressError{Op: "read", Addr: -1, Size: 4},
+			wantErr: ErrInvalidAddress,
+		},
+		{
+			desc:    "write to read-only memory",
+			access:  func() (int, error) { return memory.WriteAt([]byte{1, 1}, 5) },
+			want:    AddressError{Op: "write", Addr: 5, Size: 2},
+			wantErr: ErrReadOnly,
+		},
+	}
+
+	for _, tc := range testCases {
+		n, err := tc.access()
+		var addrErr *AddressError
+		if n != 0 || !errors.As(err, &addrErr) || !errors.Is(err, tc.wantErr) || !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("%s: got %d, %v, want 0 and %v", tc.desc, n, err, tc.wantErr)
+			continue
+		}
+
+		addrErr.Err = nil
+		if *addrErr != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.desc, *addrErr, tc.want)
+		}
+	}
+
+	if memory.mem[5] != 3 {
+		t.Errorf("memory was modified by a failed write: %v", memory.mem)
+	}
+}
@@ -0,0 +1,280 @@
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Network interface ports. Writing the address or the size of a ring resets
+// its index to the first descriptor.
+const (
+	PortNICControl    = 0xf0 // write: NICTransmit; read: NICLinkUp
+	PortNICRxRingLow  = 0xf1
+	PortNICRxRingHigh = 0xf2
+	PortNICRxRingSize = 0xf3 // in descriptors
+	PortNICTxRingLow  = 0xf4
+	PortNICTxRingHigh = 0xf5
+	PortNICTxRingSize = 0xf6 // in descriptors
+	PortNICMAC        = 0xf8 // 0xf8–0xfd, read-only
+)
+
+// NICTransmit is written to PortNICControl to transmit the frames of the
+// descriptors owned by the network interface.
+const NICTransmit = 1
+
+// NICLinkUp is read from PortNICControl when the network interface is
+// connected to a switch.
+const NICLinkUp = 1
+
+// A ring is an array of NICDescriptorSize-byte descriptors in guest memory:
+//
+//	+0  address of the buffer (16-bit)
+//	+2  length of the frame, or the size of the buffer for receiving (16-bit)
+//	+4  flags (NICOwned, NICError)
+//
+// The network interface processes descriptors in order, wrapping around at
+// the end of the ring, and stops at the first one it does not own.
+const NICDescriptorSize = 8
+
+// Descriptor flags.
+const (
+	NICOwned = 1 << 0 // owned by the network interface; cleared when processed
+	NICError = 1 << 1 // the frame could not be transmitted or received
+)
+
+// NICMaxFrameSize is the size of the largest Ethernet frame, without the
+// frame check sequence.
+const NICMaxFrameSize = 1514
+
+// nicQueueSize is the number of received frames that can wait to be
+// delivered to the guest. Further frames are dropped.
+const nicQueueSize = 64
+
+// NIC is a network interface that transmits and receives Ethernet frames
+// through a Switch. Frames are exchanged with the guest through rings of
+// descriptors in guest memory. IntNic is raised when frames are received.
+type NIC struct {
+	mac net.HardwareAddr
+	sw  *Switch
+
+	rxRing, txRing uint16
+	rxSize, txSize byte
+	rxNext, txNext byte
+
+	pending atomic.Bool // frames are queued
+	mu      sync.Mutex
+	queue   [][]byte // received frames not yet delivered to the guest
+}
+
+// NewNIC creates a network interface with the MAC address mac, which must be
+// an EUI-48 address. It has no link until it is connected to a switch.
+func NewNIC(mac net.HardwareAddr) (*NIC, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid MAC address %v: want 6 bytes, got %d", mac, len(mac))
+	}
+
+	return &NIC{mac: mac}, nil
+}
+
+// MAC returns the MAC address of the network interface.
+func (n *NIC) MAC() net.HardwareAddr {
+	return n.mac
+}
+
+func (n *NIC) Ports() []byte {
+	ports := []byte{
+		PortNICControl, PortNICRxRingLow, PortNICRxRingHigh, PortNICRxRingSize,
+		PortNICTxRingLow, PortNICTxRingHigh, PortNICTxRingSize,
+	}
+	for i := range 6 {
+		ports = append(ports, PortNICMAC+byte(i))
+	}
+
+	return ports
+}
+
+func (n *NIC) In(vm *VM, port byte) byte {
+	switch port {
+	case PortNICControl:
+		if n.sw != nil {
+			return NICLinkUp
+		}
+
+		return 0
+	case PortNICRxRingLow:
+		return byte(n.rxRing)
+	case PortNICRxRingHigh:
+		return byte(n.rxRing >> 8)
+	case PortNICRxRingSize:
+		return n.rxSize
+	case PortNICTxRingLow:
+		return byte(n.txRing)
+	case PortNICTxRingHigh:
+		return byte(n.txRing >> 8)
+	case PortNICTxRingSize:
+		return n.txSize
+	default:
+		return n.mac[port-PortNICMAC]
+	}
+}
+
+func (n *NIC) Out(vm *VM, port byte, value byte) {
+	switch port {
+	case PortNICControl:
+		if value == NICTransmit {
+			n.transmit(vm)
+		}
+	case PortNICRxRingLow:
+		n.rxRing = n.rxRing&0xff00 | uint16(value)
+		n.rxNext = 0
+	case PortNICRxRingHigh:
+		n.rxRing = n.rxRing&0x00ff | uint16(value)<<8
+		n.rxNext = 0
+	case PortNICRxRingSize:
+		n.rxSize = value
+		n.rxNext = 0
+	case PortNICTxRingLow:
+		n.txRing = n.txRing&0xff00 | uint16(value)
+		n.txNext = 0
+	case PortNICTxRingHigh:
+		n.txRing = n.txRing&0x00ff | uint16(value)<<8
+		n.txNext = 0
+	case PortNICTxRingSize:
+		n.txSize = value
+		n.txNext = 0
+	}
+}
+
+// Tick delivers the received frames to the guest.
+func (n *NIC) Tick(vm *VM) {
+	if !n.pending.Swap(false) {
+		return
+	}
+
+	n.mu.Lock()
+	queue := n.queue
+	n.queue = nil
+	n.mu.Unlock()
+
+	received := false
+	for _, frame := range queue {
+		err := n.receive(vm, frame)
+		if err != nil {
+			if vm.debug {
+				fmt.Printf("debug: NIC dropped a frame: %v\n", err)
+			}
+			continue
+		}
+
+		received = true
+	}
+
+	if received {
+		vm.interrupt(IntNic)
+	}
+}
+
+// enqueue queues a frame received from the switch. It may be called from any
+// goroutine.
+func (n *NIC) enqueue(frame []byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.queue) < nicQueueSize {
+		n.queue = append(n.queue, frame)
+		n.pending.Store(true)
+	}
+}
+
+// descriptor is a descriptor of a ring.
+type descriptor struct {
+	addr   uint16 // of the descriptor itself
+	buffer uint16
+	length uint16
+	flags  byte
+}
+
+func (n *NIC) fetchDescriptor(vm *VM, ring uint16, index byte) (descriptor, error) {
+	addr := ring + uint16(index)*NICDescriptorSize
+	data, err := vm.memory.FetchMany(addr, 5)
+	if err != nil {
+		return descriptor{}, err
+	}
+
+	return descriptor{
+		addr:   addr,
+		buffer: binary.LittleEndian.Uint16(data[0:]),
+		length: binary.LittleEndian.Uint16(data[2:]),
+		flags:  data[4],
+	}, nil
+}
+
+// release hands the descriptor back to the guest by storing it with NICOwned
+// cleared.
+func (n *NIC) release(vm *VM, d descriptor, flags byte) error {
+	data := binary.LittleEndian.AppendUint16(nil, d.buffer)
+	data = binary.LittleEndian.AppendUint16(data, d.length)
+	data = append(data, flags)
+	return vm.memory.StoreMany(d.addr, data)
+}
+
+func (n *NIC) transmit(vm *VM) {
+	for range n.txSize {
+		d, err := n.fetchDescriptor(vm, n.txRing, n.txNext)
+		if err != nil || d.flags&NICOwned == 0 {
+			return
+		}
+
+		var flags byte
+		frame, err := vm.memory.FetchMany(d.buffer, int(d.length))
+		if err != nil || d.length > NICMaxFrameSize {
+			flags = NICError
+		} else if n.sw != nil {
+			n.sw.forward(n, frame, vm.cycles)
+		}
+
+		err = n.release(vm, d, flags)
+		if err != nil {
+			return
+		}
+
+		n.txNext = (n.txNext + 1) % n.txSize
+	}
+}
+
+// receive copies the frame to the next receive descriptor. The frame is
+// dropped if the guest does not provide a descriptor.
+func (n *NIC) receive(vm *VM, frame []byte) error {
+	if n.rxSize == 0 {
+		return errors.New("no receive ring")
+	}
+
+	d, err := n.fetchDescriptor(vm, n.rxRing, n.rxNext)
+	if err != nil {
+		return err
+	}
+	if d.flags&NICOwned == 0 {
+		return errors.New("receive ring full")
+	}
+
+	var flags byte
+	if len(frame) > int(d.length) {
+		d.length = 0
+		flags = NICError
+	} else {
+		err = vm.memory.StoreMany(d.buffer, frame)
+		if err != nil {
+			d.length = 0
+			flags = NICError
+		} else {
+			d.length = uint16(len(frame))
+		}
+	}
+
+	n.rxNext = (n.rxNext + 1) % n.rxSize
+	return n.release(vm, d, flags)
+}
@@ -0,0 +1,166 @@
+package vm
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// Layout of the guest memory used by the network interface tests.
+const (
+	testRxRing   = 0x1000
+	testTxRing   = 0x1100
+	testRxBuffer = 0x2000 // 2 buffers of 0x800 bytes
+	testTxBuffer = 0x3000
+)
+
+// setUpNIC creates a machine with a network interface connected to the switch
+// and sets up its rings: a transmit ring with 1 descriptor and a receive ring
+// with 2.
+func setUpNIC(t *testing.T, sw *Switch, mac net.HardwareAddr) (*VM, *NIC) {
+	t.Helper()
+
+	vm := NewVM()
+	nic, err := NewNIC(mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.Connect(nic)
+	err = vm.AttachDevice(nic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for port, value := range map[byte]byte{
+		PortNICRxRingLow:  testRxRing & 0xff,
+		PortNICRxRingHigh: testRxRing >> 8,
+		PortNICRxRingSize: 2,
+		PortNICTxRingLow:  testTxRing & 0xff,
+		PortNICTxRingHigh: testTxRing >> 8,
+		PortNICTxRingSize: 1,
+	} {
+		nic.Out(vm, port, value)
+	}
+
+	for i := range uint16(2) {
+		_ = vm.memory.StoreMany(testRxRing+i*NICDescriptorSize, []byte{0, byte(testRxBuffer>>8 + i*8), 0x00, 0x08, NICOwned})
+	}
+
+	return vm, nic
+}
+
+// send transmits the frame through the network interface.
+func send(t *testing.T, vm *VM, nic *NIC, frame []byte) {
+	t.Helper()
+
+	_ = vm.memory.StoreMany(testTxBuffer, frame)
+	_ = vm.memory.StoreMany(testTxRing, []byte{testTxBuffer & 0xff, testTxBuffer >> 8, byte(len(frame)), byte(len(frame) >> 8), NICOwned})
+	nic.Out(vm, PortNICControl, NICTransmit)
+
+	if flags, _ := vm.memory.FetchByte(testTxRing + 4); flags != 0 {
+		t.Errorf("got transmit descriptor flags %#x, want 0", flags)
+	}
+}
+
+func ethernetFrame(dst, src net.HardwareAddr, payload string) []byte {
+	return append(append(append([]byte{}, dst...), src...), append([]byte{0x88, 0xb5}, payload...)...)
+}
+
+func TestNIC(t *testing.T) {
+	macA := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	macB := net.HardwareAddr{2, 0, 0, 0, 0, 2}
+	macC := net.HardwareAddr{2, 0, 0, 0, 0, 3}
+	broadcast := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+	sw := NewSwitch()
+	vmA, nicA := setUpNIC(t, sw, macA)
+	vmB, nicB := setUpNIC(t, sw, macB)
+	vmC, nicC := setUpNIC(t, sw, macC)
+
+	if got := nicA.In(vmA, PortNICMAC+5); got != 1 {
+		t.Errorf("got MAC byte %#x, want 1", got)
+	}
+	if got := nicA.In(vmA, PortNICControl); got != NICLinkUp {
+		t.Errorf("got control %#x, want %#x", got, NICLinkUp)
+	}
+
+	// A broadcast from B reaches A and C, and A learns where B is.
+	hello := ethernetFrame(broadcast, macB, "hello")
+	send(t, vmB, nicB, hello)
+	for _, m := range []struct {
+		vm  *VM
+		nic *NIC
+	}{{vmA, nicA}, {vmC, nicC}} {
+		m.nic.Tick(m.vm)
+		if len(m.vm.interruptQueue) != 1 || m.vm.interruptQueue[0] != IntNic {
+			t.Errorf("got interrupts %v, want %v", m.vm.interruptQueue, []int{IntNic})
+		}
+
+		descriptor, _ := m.vm.memory.FetchMany(testRxRing, 5)
+		if want := []byte{0, testRxBuffer >> 8, byte(len(hello)), 0, 0}; !bytes.Equal(descriptor, want) {
+			t.Errorf("got receive descriptor % x, want % x", descriptor, want)
+		}
+		if got, _ := m.vm.memory.FetchMany(testRxBuffer, len(hello)); !bytes.Equal(got, hello) {
+			t.Errorf("got frame % x, want % x", got, hello)
+		}
+	}
+
+	// A reply from A to B is not flooded to C.
+	reply := ethernetFrame(macB, macA, "hi")
+	send(t, vmA, nicA, reply)
+	nicB.Tick(vmB)
+	nicC.Tick(vmC)
+	if got, _ := vmB.memory.FetchMany(testRxBuffer, len(reply)); !bytes.Equal(got, reply) {
+		t.Errorf("got frame % x, want % x", got, reply)
+	}
+	if len(vmC.interruptQueue) != 1 {
+		t.Errorf("got interrupts %v in C, want only the broadcast", vmC.interruptQueue)
+	}
+
+	// C's second descriptor is still free, then the ring is full.
+	send(t, vmA, nicA, ethernetFrame(macC, macA, "1"))
+	send(t, vmA, nicA, ethernetFrame(macC, macA, "2"))
+	nicC.Tick(vmC)
+	if flags, _ := vmC.memory.FetchByte(testRxRing + NICDescriptorSize + 4); flags != 0 {
+		t.Errorf("got flags %#x of the second receive descriptor, want 0", flags)
+	}
+	if got, _ := vmC.memory.FetchByte(testRxBuffer + 0x800 + 14); got != '1' {
+		t.Errorf("got payload %q, want %q", got, '1')
+	}
+}
+
+func TestNICConcurrent(t *testing.T) {
+	macA := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	macB := net.HardwareAddr{2, 0, 0, 0, 0, 2}
+	sw := NewSwitch()
+	vmA, nicA := setUpNIC(t, sw, macA)
+	vmB, _ := setUpNIC(t, sw, macB)
+	_ = vmB.memory.StoreMany(0, []byte{0x40, 0xfd, 0xff}) // vjmp $
+
+	go send(t, vmA, nicA, ethernetFrame(macB, macA, "ping"))
+	for range 1_000_000 {
+		err := vmB.runSingleStep()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(vmB.interruptQueue) != 0 {
+			return
+		}
+	}
+
+	t.Error("frame not received")
+}
+
+func TestNewNICInvalidMAC(t *testing.T) {
+	for _, mac := range []net.HardwareAddr{
+		nil,
+		{0x02, 0, 0, 0, 0},
+		{0x02, 0, 0, 0, 0, 0, 0, 0x01},
+	} {
+		_, err := NewNIC(mac)
+		if err == nil {
+			t.Errorf("NewNIC(%v): expected an error", mac)
+		}
+	}
+}
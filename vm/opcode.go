@@ -0,0 +1,77 @@
+package vm
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	"github.com/bartekpacia/toyvm/isa"
+)
+
+// opcodesMutex guards opcodes against RegisterOpcode.
+var opcodesMutex sync.RWMutex
+
+// Instruction describes an instruction added with RegisterOpcode.
+type Instruction struct {
+	// Handler executes the instruction. It gets the operands as they are
+	// encoded in the machine code, in the order of Operands, and reports
+	// errors by raising interrupts, like the built-in instructions do.
+	Handler InstructionHandler
+
+	// Mnemonic is the name of the instruction in assembly, for example
+	// "vpopcnt".
+	Mnemonic string
+
+	// Operands are the kinds of the operands, in the order they are written
+	// and encoded.
+	Operands []isa.Operand
+
+	// Cycles is the virtual time the instruction takes. 0 is taken as 1.
+	Cycles int
+}
+
+// RegisterOpcode adds an instruction to the instruction set. It fails if the
+// opcode or the mnemonic is already in use. The instruction is available in
+// machines created afterwards, and to the assembler and the disassembler, so
+// it should be registered before any of them are used, for example in an init
+// function.
+func RegisterOpcode(code byte, in Instruction) error {
+	if in.Handler == nil {
+		return fmt.Errorf("%s: no handler", in.Mnemonic)
+	}
+
+	opcodesMutex.Lock()
+	defer opcodesMutex.Unlock()
+
+	if other, ok := opcodes[code]; ok {
+		return fmt.Errorf("%w: 0x%02x is V%s", isa.ErrOpcodeInUse, code, other.mnemonic)
+	}
+
+	err := isa.Register(in.Mnemonic, isa.Instruction{Opcode: code, Operands: in.Operands})
+	if err != nil {
+		return err
+	}
+
+	length := 0
+	for _, kind := range in.Operands {
+		length += kind.Size()
+	}
+
+	opcodes[code] = opcode{
+		handler:  in.Handler,
+		length:   length,
+		mnemonic: strings.ToUpper(strings.TrimPrefix(strings.ToLower(in.Mnemonic), "v")),
+		cycles:   in.Cycles,
+	}
+
+	return nil
+}
+
+// instructionSet returns a copy of the instruction set for a new machine.
+func instructionSet() map[byte]opcode {
+	opcodesMutex.RLock()
+	defer opcodesMutex.RUnlock()
+
+	return maps.Clone(opcodes)
+}
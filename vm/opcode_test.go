@@ -0,0 +1,127 @@
+package vm
+
+import (
+	"errors"
+	"math/bits"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bartekpacia/toyvm/asm"
+	"github.com/bartekpacia/toyvm/isa"
+)
+
+// registerPopcnt registers VPOPCNT rdst, rsrc, which counts the set bits of
+// rsrc. Registration is global, so it happens once for all tests.
+var registerPopcnt = sync.OnceValue(func() error {
+	return RegisterOpcode(0x90, Instruction{
+		Handler: func(vm *VM, args []byte) {
+			vm.reg[args[0]].value = uint32(bits.OnesCount32(vm.reg[args[1]].value))
+		},
+		Mnemonic: "vpopcnt",
+		Operands: []isa.Operand{isa.Reg, isa.Reg},
+		Cycles:   3,
+	})
+})
+
+func TestRegisterOpcode(t *testing.T) {
+	err := registerPopcnt()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program, err := asm.Assemble([]byte("vset r1, 0xf0f0\nvpopcnt r0, r1\nvoff\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm := NewVM()
+	_ = vm.memory.StoreMany(0, program)
+	err = vm.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vm.reg[0].value != 8 {
+		t.Errorf("got r0 = %d, want 8", vm.reg[0].value)
+	}
+	if stats := vm.Stats(); stats.Instructions != 3 || stats.Cycles != 1+3+1 {
+		t.Errorf("got %d instructions and %d cycles, want 3 and 5", stats.Instructions, stats.Cycles)
+	}
+
+	lines := asm.Disassemble(program[6:9], 0)
+	if len(lines) != 1 || lines[0].Text != "vpopcnt r0, r1" {
+		t.Errorf("got disassembly %v, want vpopcnt r0, r1", lines)
+	}
+}
+
+func TestRegisterOpcodeConflicts(t *testing.T) {
+	err := registerPopcnt()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := func(vm *VM, args []byte) {}
+	testCases := []struct {
+		desc string
+		code byte
+		in   Instruction
+		want error
+	}{
+		{
+			desc: "built-in opcode",
+			code: 0x00,
+			in:   Instruction{Handler: handler, Mnemonic: "vfoo"},
+			want: isa.ErrOpcodeInUse,
+		},
+		{
+			desc: "registered opcode",
+			code: 0x90,
+			in:   Instruction{Handler: handler, Mnemonic: "vfoo"},
+			want: isa.ErrOpcodeInUse,
+		},
+		{
+			desc: "built-in mnemonic",
+			code: 0x91,
+			in:   Instruction{Handler: handler, Mnemonic: "VMOV"},
+			want: isa.ErrMnemonicInUse,
+		},
+		{
+			desc: "alias",
+			code: 0x91,
+			in:   Instruction{Handler: handler, Mnemonic: "vje"},
+			want: isa.ErrMnemonicInUse,
+		},
+		{
+			desc: "registered mnemonic",
+			code: 0x91,
+			in:   Instruction{Handler: handler, Mnemonic: "vpopcnt"},
+			want: isa.ErrMnemonicInUse,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := RegisterOpcode(tc.code, tc.in)
+			if !errors.Is(err, tc.want) {
+				t.Errorf("got error %v, want %v", err, tc.want)
+			}
+		})
+	}
+
+	for _, in := range []Instruction{
+		{Mnemonic: "vfoo"},
+		{Handler: handler},
+		{Handler: handler, Mnemonic: "vfoo", Operands: []isa.Operand{42}},
+	} {
+		err := RegisterOpcode(0x91, in)
+		if err == nil {
+			t.Errorf("registering %+v: expected an error", in)
+		}
+	}
+
+	_, err = asm.Assemble([]byte("vfoo"))
+	if err == nil || !strings.Contains(err.Error(), "unknown instruction") {
+		t.Errorf("got error %v after failed registrations, want unknown instruction", err)
+	}
+}
@@ -0,0 +1,90 @@
+// Code generated by isagen from isa/isa.json. DO NOT EDIT.
+
+package vm
+
+var opcodes = map[byte]opcode{
+	// data copying instructions
+	0x00: {handler: VMOV, length: 1 + 1, mnemonic: "MOV"},
+	0x01: {handler: VSET, length: 1 + 4, mnemonic: "SET"},
+	0x02: {handler: VLD, length: 1 + 1, mnemonic: "LD"},
+	0x03: {handler: VST, length: 1 + 1, mnemonic: "ST"},
+	0x04: {handler: VLDB, length: 1 + 1, mnemonic: "LDB"},
+	0x05: {handler: VSTB, length: 1 + 1, mnemonic: "STB"},
+	0x06: {handler: VLDW, length: 1 + 1, mnemonic: "LDW"},
+	0x07: {handler: VLDSW, length: 1 + 1, mnemonic: "LDSW"},
+	0x08: {handler: VSTW, length: 1 + 1, mnemonic: "STW"},
+	0x09: {handler: VLDSP, length: 1 + 2, mnemonic: "LDSP"},
+	0x0A: {handler: VSTSP, length: 1 + 2, mnemonic: "STSP"},
+	// arithmetic and logic instructions
+	0x10: {handler: VADD, length: 1 + 1, mnemonic: "ADD"},
+	0x11: {handler: VSUB, length: 1 + 1, mnemonic: "SUB"},
+	0x12: {handler: VMUL, length: 1 + 1, mnemonic: "MUL"},
+	0x13: {handler: VDIV, length: 1 + 1, mnemonic: "DIV"},
+	0x14: {handler: VMOD, length: 1 + 1, mnemonic: "MOD"},
+	0x15: {handler: VOR, length: 1 + 1, mnemonic: "OR"},
+	0x16: {handler: VAND, length: 1 + 1, mnemonic: "AND"},
+	0x17: {handler: VXOR, length: 1 + 1, mnemonic: "XOR"},
+	0x18: {handler: VNOT, length: 1, mnemonic: "NOT"},
+	0x19: {handler: VSHL, length: 1 + 1, mnemonic: "SHL"},
+	0x1A: {handler: VSHR, length: 1 + 1, mnemonic: "SHR"},
+	// comparison and conditional jump instructions
+	0x20: {handler: VCMP, length: 1 + 1, mnemonic: "CMP"},
+	0x21: {handler: VJZ, length: 2, mnemonic: "JZ"},
+	0x22: {handler: VJNZ, length: 2, mnemonic: "JNZ"},
+	0x23: {handler: VJC, length: 2, mnemonic: "JC"},
+	0x24: {handler: VJNC, length: 2, mnemonic: "JNC"},
+	0x25: {handler: VJBE, length: 2, mnemonic: "JBE"},
+	0x26: {handler: VJA, length: 2, mnemonic: "JA"},
+	0x27: {handler: VJL, length: 2, mnemonic: "JL"},
+	0x28: {handler: VJGE, length: 2, mnemonic: "JGE"},
+	0x29: {handler: VJLE, length: 2, mnemonic: "JLE"},
+	0x2A: {handler: VJG, length: 2, mnemonic: "JG"},
+	// stack operation instructions
+	0x30: {handler: VPUSH, length: 1, mnemonic: "PUSH"},
+	0x31: {handler: VPOP, length: 1, mnemonic: "POP"},
+	// unconditional jump instructions
+	0x40: {handler: VJMP, length: 2, mnemonic: "JMP"},
+	0x41: {handler: VJMPR, length: 1, mnemonic: "JMPR"},
+	0x42: {handler: VCALL, length: 2, mnemonic: "CALL"},
+	0x43: {handler: VCALLR, length: 1, mnemonic: "CALLR"},
+	0x44: {handler: VRET, length: 0, mnemonic: "RET"},
+	// extended arithmetic instructions
+	0x50: {handler: VIDIV, length: 1 + 1, mnemonic: "IDIV"},
+	0x51: {handler: VIMOD, length: 1 + 1, mnemonic: "IMOD"},
+	0x52: {handler: VSAR, length: 1 + 1, mnemonic: "SAR"},
+	0x53: {handler: VROL, length: 1 + 1, mnemonic: "ROL"},
+	0x54: {handler: VROR, length: 1 + 1, mnemonic: "ROR"},
+	0x55: {handler: VADC, length: 1 + 1, mnemonic: "ADC"},
+	0x56: {handler: VSBB, length: 1 + 1, mnemonic: "SBB"},
+	0x57: {handler: VMULL, length: 1 + 1, mnemonic: "MULL"},
+	// atomic instructions
+	0x60: {handler: VCAS, length: 1 + 1 + 1, mnemonic: "CAS"},
+	0x61: {handler: VXCHG, length: 1 + 1, mnemonic: "XCHG"},
+	// floating-point instructions
+	0x70: {handler: VFADD, length: 1 + 1, mnemonic: "FADD"},
+	0x71: {handler: VFSUB, length: 1 + 1, mnemonic: "FSUB"},
+	0x72: {handler: VFMUL, length: 1 + 1, mnemonic: "FMUL"},
+	0x73: {handler: VFDIV, length: 1 + 1, mnemonic: "FDIV"},
+	0x74: {handler: VFCMP, length: 1 + 1, mnemonic: "FCMP"},
+	0x75: {handler: VITOF, length: 1, mnemonic: "ITOF"},
+	0x76: {handler: VFTOI, length: 1, mnemonic: "FTOI"},
+	// displacement addressing instructions
+	0x80: {handler: VLDO, length: 1 + 1 + 2, mnemonic: "LDO"},
+	0x81: {handler: VSTO, length: 1 + 1 + 2, mnemonic: "STO"},
+	0x82: {handler: VLDBO, length: 1 + 1 + 2, mnemonic: "LDBO"},
+	0x83: {handler: VSTBO, length: 1 + 1 + 2, mnemonic: "STBO"},
+	0x84: {handler: VLDWO, length: 1 + 1 + 2, mnemonic: "LDWO"},
+	0x85: {handler: VLDSWO, length: 1 + 1 + 2, mnemonic: "LDSWO"},
+	0x86: {handler: VSTWO, length: 1 + 1 + 2, mnemonic: "STWO"},
+	// additional control instructions
+	0xF0: {handler: VCRL, length: 1 + 2, mnemonic: "CRL"},
+	0xF1: {handler: VCRS, length: 1 + 2, mnemonic: "CRS"},
+	0xF2: {handler: VOUTB, length: 1 + 1, mnemonic: "OUTB"},
+	0xF3: {handler: VINB, length: 1 + 1, mnemonic: "INB"},
+	0xF4: {handler: VIRET, length: 0, mnemonic: "IRET"},
+	0xF5: {handler: VINT, length: 1, mnemonic: "INT"},
+	0xF6: {handler: VHLT, length: 0, mnemonic: "HLT"},
+	0xF7: {handler: VHCALL, length: 2, mnemonic: "HCALL"},
+	0xFE: {handler: VCRSH, length: 0, mnemonic: "CRSH"},
+	0xFF: {handler: VOFF, length: 0, mnemonic: "OFF"},
+}
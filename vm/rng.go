@@ -0,0 +1,29 @@
+package vm
+
+import "math/rand/v2"
+
+// PortRNG is the port of the random number generator. Every read returns a
+// new random byte.
+const PortRNG = 0xc0
+
+// RNG is a random number generator device. It is seeded by the host, so that
+// runs can be reproduced.
+type RNG struct {
+	rand *rand.Rand
+}
+
+// NewRNG creates a random number generator that produces the same sequence of
+// bytes for the same seed.
+func NewRNG(seed uint64) *RNG {
+	return &RNG{rand: rand.New(rand.NewPCG(seed, 0))}
+}
+
+func (r *RNG) Ports() []byte {
+	return []byte{PortRNG}
+}
+
+func (r *RNG) In(vm *VM, port byte) byte {
+	return byte(r.rand.Uint32())
+}
+
+func (r *RNG) Out(vm *VM, port byte, value byte) {}
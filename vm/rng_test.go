@@ -0,0 +1,26 @@
+package vm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRNGSeed(t *testing.T) {
+	read := func(rng *RNG) []byte {
+		vm := NewVM()
+		out := make([]byte, 16)
+		for i := range out {
+			out[i] = rng.In(vm, PortRNG)
+		}
+
+		return out
+	}
+
+	a, b, c := read(NewRNG(1)), read(NewRNG(1)), read(NewRNG(2))
+	if !bytes.Equal(a, b) {
+		t.Errorf("got %x and %x for the same seed, want equal", a, b)
+	}
+	if bytes.Equal(a, c) {
+		t.Errorf("got %x for different seeds, want different", a)
+	}
+}
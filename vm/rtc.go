@@ -0,0 +1,142 @@
+package vm
+
+import "time"
+
+// Real-time clock ports. The guest writes the index of a register to
+// PortRTCIndex, then reads or writes it through PortRTCData. The index is
+// incremented after every access to the data port, so multi-byte registers can
+// be accessed without setting it again. Writing the index also latches the
+// current time, so the registers read afterwards are consistent. If the index
+// has not been written yet, the first read of the data port latches it.
+const (
+	PortRTCIndex = 0xc8
+	PortRTCData  = 0xc9
+)
+
+// Real-time clock registers. The date and time registers are BCD-encoded and
+// read-only. Multi-byte registers are little-endian.
+const (
+	RTCSeconds = 0x00
+	RTCMinutes = 0x01
+	RTCHours   = 0x02
+	RTCDay     = 0x03
+	RTCMonth   = 0x04
+	RTCYear    = 0x05 // last two digits of the year
+	RTCCentury = 0x06
+	RTCEpoch   = 0x08 // 4 bytes, seconds since 1970-01-01 00:00:00 UTC
+	RTCAlarm   = 0x0c // 4 bytes, in the same format as RTCEpoch
+	RTCControl = 0x10
+)
+
+// Bits of the RTCControl register.
+const (
+	RTCAlarmEnable = 1 << 0 // raise IntRtc at the alarm time; cleared when it fires
+)
+
+// RTC is a real-time clock device. It keeps either wall-clock time or virtual
+// time, derived from the number of cycles and ClockRate. It can raise IntRtc
+// when the alarm time is reached.
+type RTC struct {
+	start   time.Time // of virtual time; zero for wall-clock time
+	index   byte
+	latched time.Time
+	alarm   uint32
+	control byte
+}
+
+// NewRTC creates a real-time clock that keeps wall-clock time.
+func NewRTC() *RTC {
+	return &RTC{}
+}
+
+// NewVirtualRTC creates a real-time clock that starts at start and advances
+// with virtual time, so that runs can be reproduced.
+func NewVirtualRTC(start time.Time) *RTC {
+	return &RTC{start: start}
+}
+
+// Now returns the current time of the clock.
+func (r *RTC) Now(vm *VM) time.Time {
+	if r.start.IsZero() {
+		return time.Now().UTC()
+	}
+
+	elapsed := time.Duration(vm.cycles) * (time.Second / ClockRate)
+	return r.start.Add(elapsed).UTC()
+}
+
+func (r *RTC) Ports() []byte {
+	return []byte{PortRTCIndex, PortRTCData}
+}
+
+func (r *RTC) In(vm *VM, port byte) byte {
+	if port == PortRTCIndex {
+		return r.index
+	}
+
+	defer func() { r.index++ }()
+
+	if r.latched.IsZero() {
+		r.latched = r.Now(vm)
+	}
+
+	t := r.latched
+	switch i := r.index; {
+	case i == RTCSeconds:
+		return bcd(t.Second())
+	case i == RTCMinutes:
+		return bcd(t.Minute())
+	case i == RTCHours:
+		return bcd(t.Hour())
+	case i == RTCDay:
+		return bcd(t.Day())
+	case i == RTCMonth:
+		return bcd(int(t.Month()))
+	case i == RTCYear:
+		return bcd(t.Year() % 100)
+	case i == RTCCentury:
+		return bcd(t.Year() / 100)
+	case i >= RTCEpoch && i < RTCEpoch+4:
+		return byte(uint32(t.Unix()) >> (8 * (i - RTCEpoch)))
+	case i >= RTCAlarm && i < RTCAlarm+4:
+		return byte(r.alarm >> (8 * (i - RTCAlarm)))
+	case i == RTCControl:
+		return r.control
+	default:
+		return 0
+	}
+}
+
+func (r *RTC) Out(vm *VM, port byte, value byte) {
+	if port == PortRTCIndex {
+		r.index = value
+		r.latched = r.Now(vm)
+		return
+	}
+
+	switch i := r.index; {
+	case i >= RTCAlarm && i < RTCAlarm+4:
+		shift := 8 * (i - RTCAlarm)
+		r.alarm = r.alarm&^(0xff<<shift) | uint32(value)<<shift
+	case i == RTCControl:
+		r.control = value
+	}
+
+	r.index++
+}
+
+func (r *RTC) Tick(vm *VM) {
+	if r.control&RTCAlarmEnable == 0 {
+		return
+	}
+
+	if r.Now(vm).Unix() >= int64(r.alarm) {
+		r.control &^= RTCAlarmEnable
+		vm.interrupt(IntRtc)
+	}
+}
+
+// bcd encodes a number from 0 to 99 in binary-coded decimal.
+func bcd(n int) byte {
+	return byte(n/10<<4 | n%10)
+}
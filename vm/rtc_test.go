@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRTCRegisters(t *testing.T) {
+	start := time.Date(2024, time.March, 9, 23, 58, 7, 0, time.UTC)
+	rtc := NewVirtualRTC(start)
+	vm := NewVM()
+	vm.cycles = 2 * ClockRate
+
+	rtc.Out(vm, PortRTCIndex, RTCSeconds)
+	vm.cycles += ClockRate // the latched time must not change
+	got := make([]byte, RTCEpoch+4)
+	for i := range got {
+		got[i] = rtc.In(vm, PortRTCData)
+	}
+
+	want := []byte{0x09, 0x58, 0x23, 0x09, 0x03, 0x24, 0x20}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("register %d: got %#02x, want %#02x", i, got[i], w)
+		}
+	}
+
+	epoch := uint32(got[RTCEpoch]) | uint32(got[RTCEpoch+1])<<8 | uint32(got[RTCEpoch+2])<<16 | uint32(got[RTCEpoch+3])<<24
+	if want := uint32(start.Unix() + 2); epoch != want {
+		t.Errorf("got epoch %d, want %d", epoch, want)
+	}
+}
+
+func TestRTCFirstReadLatches(t *testing.T) {
+	rtc := NewVirtualRTC(time.Unix(1000, 0))
+	vm := NewVM()
+	vm.cycles = 5 * ClockRate
+
+	// The index is RTCSeconds at reset, but it has never been written.
+	if got := rtc.In(vm, PortRTCData); got != 0x45 {
+		t.Errorf("got seconds %#02x, want 0x45", got)
+	}
+}
+
+func TestRTCAlarm(t *testing.T) {
+	start := time.Unix(1000, 0)
+	rtc := NewVirtualRTC(start)
+	vm := NewVM()
+
+	rtc.Out(vm, PortRTCIndex, RTCAlarm)
+	for _, b := range []byte{0xea, 0x03, 0, 0, RTCAlarmEnable} { // 1002, then RTCControl
+		rtc.Out(vm, PortRTCData, b)
+	}
+
+	vm.cycles = ClockRate
+	rtc.Tick(vm)
+	if len(vm.interruptQueue) != 0 {
+		t.Fatalf("got interrupts %v before the alarm time, want none", vm.interruptQueue)
+	}
+
+	vm.cycles = 2 * ClockRate
+	rtc.Tick(vm)
+	rtc.Tick(vm)
+	if len(vm.interruptQueue) != 1 || vm.interruptQueue[0] != IntRtc {
+		t.Errorf("got interrupts %v, want %v", vm.interruptQueue, []int{IntRtc})
+	}
+	if rtc.control&RTCAlarmEnable != 0 {
+		t.Error("alarm still enabled after it fired")
+	}
+}
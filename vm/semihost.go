@@ -0,0 +1,232 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Semihosting ports. To make a request, the guest writes the address of a
+// request block to PortSemihostAddr, low byte first. Writing the high byte
+// performs the request. Reading PortSemihostAddr returns 0 if the last request
+// succeeded and 1 otherwise.
+const (
+	PortSemihostAddr = 0x60
+)
+
+// Semihosting operations.
+const (
+	SemihostOpen  = iota + 1 // arg0: path address, arg1: path length, arg2: mode; result: fd
+	SemihostClose            // arg0: fd
+	SemihostRead             // arg0: fd, arg1: buffer address, arg2: length; result: bytes read
+	SemihostWrite            // arg0: fd, arg1: buffer address, arg2: length; result: bytes written
+	SemihostSeek             // arg0: fd, arg1: offset, arg2: whence; result: new offset
+	SemihostTime             // result: seconds since the Unix epoch
+)
+
+// Modes of SemihostOpen.
+const (
+	SemihostModeRead      = iota // read only
+	SemihostModeWrite            // write only, create or truncate
+	SemihostModeAppend           // write only, create and append
+	SemihostModeReadWrite        // read and write, create
+)
+
+// Layout of a request block. Each field is a dword. The result is written by
+// the host and is 0xffffffff if the request failed.
+const (
+	semihostFieldOp     = 0
+	semihostFieldArg0   = 4
+	semihostFieldArg1   = 8
+	semihostFieldArg2   = 12
+	semihostFieldResult = 16
+)
+
+const semihostFailure = 0xffffffff
+
+// Semihost is a device that lets the guest access files in a host directory.
+// Paths are resolved relative to that directory and cannot escape it.
+type Semihost struct {
+	root   *os.Root
+	files  map[uint32]*os.File
+	nextFd uint32
+
+	addr   uint16
+	high   bool // next write to PortSemihostAddr is the high byte
+	status byte
+}
+
+// NewSemihost creates a semihosting device sandboxed to dir.
+func NewSemihost(dir string) (*Semihost, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open semihosting root: %w", err)
+	}
+
+	return &Semihost{
+		root:   root,
+		files:  make(map[uint32]*os.File),
+		nextFd: 1,
+	}, nil
+}
+
+func (s *Semihost) Ports() []byte {
+	return []byte{PortSemihostAddr}
+}
+
+func (s *Semihost) In(vm *VM, port byte) byte {
+	return s.status
+}
+
+func (s *Semihost) Out(vm *VM, port byte, value byte) {
+	if !s.high {
+		s.addr = uint16(value)
+		s.high = true
+		return
+	}
+
+	s.addr |= uint16(value) << 8
+	s.high = false
+	s.request(vm, s.addr)
+}
+
+// Close closes all files opened by the guest and the root directory.
+func (s *Semihost) Close() error {
+	for fd, f := range s.files {
+		f.Close()
+		delete(s.files, fd)
+	}
+
+	return s.root.Close()
+}
+
+func (s *Semihost) request(vm *VM, addr uint16) {
+	var fields [4]uint32
+	offsets := []uint16{semihostFieldOp, semihostFieldArg0, semihostFieldArg1, semihostFieldArg2}
+	for i, offset := range offsets {
+		value, err := vm.memory.FetchDword(addr + offset)
+		if err != nil {
+			vm.interrupt(IntMemoryError)
+			return
+		}
+		fields[i] = value
+	}
+
+	op := fields[0]
+	args := fields[1:]
+	result, err := s.perform(vm, op, args)
+	if err != nil {
+		if vm.debug {
+			fmt.Printf("debug: semihosting request %d failed: %v\n", op, err)
+		}
+		result = semihostFailure
+		s.status = 1
+	} else {
+		s.status = 0
+	}
+
+	err = vm.memory.StoreDword(addr+semihostFieldResult, result)
+	if err != nil {
+		vm.interrupt(IntMemoryError)
+	}
+}
+
+func (s *Semihost) perform(vm *VM, op uint32, args []uint32) (uint32, error) {
+	switch op {
+	case SemihostOpen:
+		path, err := vm.memory.FetchMany(uint16(args[0]), int(args[1]))
+		if err != nil {
+			return 0, err
+		}
+
+		var flag int
+		switch args[2] {
+		case SemihostModeRead:
+			flag = os.O_RDONLY
+		case SemihostModeWrite:
+			flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		case SemihostModeAppend:
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		case SemihostModeReadWrite:
+			flag = os.O_RDWR | os.O_CREATE
+		default:
+			return 0, fmt.Errorf("invalid mode %d", args[2])
+		}
+
+		f, err := s.root.OpenFile(string(path), flag, 0o644)
+		if err != nil {
+			return 0, err
+		}
+
+		fd := s.nextFd
+		s.nextFd++
+		s.files[fd] = f
+		return fd, nil
+	case SemihostClose:
+		f, err := s.file(args[0])
+		if err != nil {
+			return 0, err
+		}
+
+		delete(s.files, args[0])
+		return 0, f.Close()
+	case SemihostRead:
+		f, err := s.file(args[0])
+		if err != nil {
+			return 0, err
+		}
+
+		if args[2] > uint32(len(vm.memory.mem)) {
+			return 0, fmt.Errorf("invalid length %d", args[2])
+		}
+
+		buf := make([]byte, args[2])
+		n, err := f.Read(buf)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		err = vm.memory.StoreMany(uint16(args[1]), buf[:n])
+		if err != nil {
+			return 0, err
+		}
+
+		return uint32(n), nil
+	case SemihostWrite:
+		f, err := s.file(args[0])
+		if err != nil {
+			return 0, err
+		}
+
+		data, err := vm.memory.FetchMany(uint16(args[1]), int(args[2]))
+		if err != nil {
+			return 0, err
+		}
+
+		n, err := f.Write(data)
+		return uint32(n), err
+	case SemihostSeek:
+		f, err := s.file(args[0])
+		if err != nil {
+			return 0, err
+		}
+
+		offset, err := f.Seek(int64(int32(args[1])), int(args[2]))
+		return uint32(offset), err
+	case SemihostTime:
+		return uint32(time.Now().Unix()), nil
+	default:
+		return 0, fmt.Errorf("invalid operation %d", op)
+	}
+}
+
+func (s *Semihost) file(fd uint32) (*os.File, error) {
+	f, ok := s.files[fd]
+	if !ok {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,90 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// semihostRequest writes a request block at addr and performs the request.
+func semihostRequest(vm *VM, s *Semihost, addr uint16, op uint32, args ...uint32) uint32 {
+	_ = vm.memory.StoreDword(addr+semihostFieldOp, op)
+	for i, arg := range args {
+		_ = vm.memory.StoreDword(addr+semihostFieldArg0+uint16(4*i), arg)
+	}
+
+	s.Out(vm, PortSemihostAddr, byte(addr))
+	s.Out(vm, PortSemihostAddr, byte(addr>>8))
+
+	result, _ := vm.memory.FetchDword(addr + semihostFieldResult)
+	return result
+}
+
+func TestSemihost(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("fixture"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSemihost(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	vm := NewVM()
+	const block, path, buf = 0x1000, 0x2000, 0x3000
+
+	_ = vm.memory.StoreMany(path, []byte("in.txt"))
+	fd := semihostRequest(vm, s, block, SemihostOpen, path, 6, SemihostModeRead)
+	if fd == semihostFailure {
+		t.Fatal("failed to open in.txt")
+	}
+
+	n := semihostRequest(vm, s, block, SemihostRead, fd, buf, 100)
+	got, _ := vm.memory.FetchMany(buf, int(n))
+	if string(got) != "fixture" {
+		t.Errorf("got %q, want %q", got, "fixture")
+	}
+
+	semihostRequest(vm, s, block, SemihostSeek, fd, 3, 0)
+	n = semihostRequest(vm, s, block, SemihostRead, fd, buf, 100)
+	got, _ = vm.memory.FetchMany(buf, int(n))
+	if string(got) != "ture" {
+		t.Errorf("got %q, want %q", got, "ture")
+	}
+
+	if result := semihostRequest(vm, s, block, SemihostClose, fd); result != 0 {
+		t.Errorf("got %#x, want 0", result)
+	}
+
+	_ = vm.memory.StoreMany(path, []byte("out.txt"))
+	fd = semihostRequest(vm, s, block, SemihostOpen, path, 7, SemihostModeWrite)
+	semihostRequest(vm, s, block, SemihostWrite, fd, buf, 4)
+	semihostRequest(vm, s, block, SemihostClose, fd)
+
+	data, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if err != nil || string(data) != "ture" {
+		t.Errorf("got %q (%v), want %q", data, err, "ture")
+	}
+}
+
+func TestSemihostSandbox(t *testing.T) {
+	s, err := NewSemihost(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	vm := NewVM()
+	_ = vm.memory.StoreMany(0x2000, []byte("../escape"))
+
+	fd := semihostRequest(vm, s, 0x1000, SemihostOpen, 0x2000, 9, SemihostModeWrite)
+	if fd != semihostFailure {
+		t.Errorf("got fd %d, want failure", fd)
+	}
+	if status := s.In(vm, PortSemihostAddr); status != 1 {
+		t.Errorf("got status %d, want 1", status)
+	}
+}
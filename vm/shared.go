@@ -0,0 +1,48 @@
+package vm
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SharedMemory is a region of memory that can be mapped into several machines
+// running in separate goroutines. Each access to it is done while holding a
+// lock, so a dword stored by one machine is never seen half-written by
+// another.
+type SharedMemory struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+// NewSharedMemory creates a zeroed shared memory region of size bytes.
+func NewSharedMemory(size int) *SharedMemory {
+	return &SharedMemory{data: make([]byte, size)}
+}
+
+// Size returns the size of the region in bytes.
+func (s *SharedMemory) Size() int {
+	return len(s.data)
+}
+
+// MapSharedMemory maps the shared memory region into the memory of the
+// machine at addr. It must be called before the machine starts running. The
+// previous contents of the memory at addr are hidden while it is mapped.
+func (vm *VM) MapSharedMemory(addr uint16, shared *SharedMemory) error {
+	mapping := sharedMapping{
+		span:   span{start: int(addr), end: int(addr) + shared.Size()},
+		memory: shared,
+	}
+
+	if mapping.end > len(vm.memory.mem) {
+		return fmt.Errorf("%w: %d bytes of shared memory at %d", ErrInvalidAddress, shared.Size(), addr)
+	}
+
+	for _, other := range vm.memory.shared {
+		if mapping.start < other.end && mapping.end > other.start {
+			return fmt.Errorf("%w: shared memory at %d overlaps another mapping", ErrInvalidAddress, addr)
+		}
+	}
+
+	vm.memory.shared = append(vm.memory.shared, mapping)
+	return nil
+}
@@ -0,0 +1,67 @@
+package vm
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestSharedMemory(t *testing.T) {
+	shared := NewSharedMemory(16)
+	vmA, vmB := NewVM(), NewVM()
+	_ = vmA.memory.StoreMany(0x1000, []byte{0xaa, 0xaa, 0xaa, 0xaa})
+	for _, vm := range []*VM{vmA, vmB} {
+		err := vm.MapSharedMemory(0x1000, shared)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_ = vmA.memory.StoreDword(0x1004, 0x12345678)
+	if got, _ := vmB.memory.FetchDword(0x1004); got != 0x12345678 {
+		t.Errorf("got %#x, want 0x12345678", got)
+	}
+	if got, _ := vmB.memory.FetchByte(0x1000); got != 0 {
+		t.Errorf("got %#x, want the shared 0", got)
+	}
+
+	// A dword that is half shared.
+	_ = vmA.memory.StoreDword(0x100e, 0x11223344)
+	if got, _ := vmB.memory.FetchMany(0x100e, 4); got[0] != 0x44 || got[1] != 0x33 || got[2] != 0 {
+		t.Errorf("got % x, want the shared half only", got)
+	}
+	if got, _ := vmA.memory.FetchDword(0x100e); got != 0x11223344 {
+		t.Errorf("got %#x, want 0x11223344", got)
+	}
+
+	if err := vmA.MapSharedMemory(0x1008, NewSharedMemory(16)); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("got %v for an overlapping mapping, want %v", err, ErrInvalidAddress)
+	}
+	if err := vmA.MapSharedMemory(0xfff8, NewSharedMemory(16)); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("got %v for a mapping past the end of memory, want %v", err, ErrInvalidAddress)
+	}
+}
+
+func TestSharedMemoryConcurrent(t *testing.T) {
+	shared := NewSharedMemory(4)
+	vms := []*VM{NewVM(), NewVM()}
+	var wg sync.WaitGroup
+	for i, vm := range vms {
+		_ = vm.MapSharedMemory(0x2000, shared)
+		value := uint32(0x11111111 * (i + 1))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				_ = vm.memory.StoreDword(0x2000, value)
+				got, _ := vm.memory.FetchDword(0x2000)
+				if got != 0x11111111 && got != 0x22222222 {
+					t.Errorf("got torn dword %#x", got)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,119 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Switch is an in-process Ethernet switch that connects network interfaces,
+// typically of machines running in separate goroutines. It learns the MAC
+// addresses of the interfaces from the frames they transmit. Frames to
+// broadcast, multicast and unknown addresses are flooded to all the other
+// interfaces.
+type Switch struct {
+	mu      sync.Mutex
+	nics    []*NIC
+	table   map[string]*NIC // by MAC address
+	capture io.Writer
+}
+
+// NewSwitch creates a switch with no interfaces connected.
+func NewSwitch() *Switch {
+	return &Switch{table: make(map[string]*NIC)}
+}
+
+// Connect connects the network interface to the switch. It must be called
+// before the machine of the interface starts running.
+func (s *Switch) Connect(nic *NIC) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nic.sw = s
+	s.nics = append(s.nics, nic)
+}
+
+// Capture logs all frames forwarded by the switch to out, in the pcap format.
+// The timestamp of a frame is the virtual time of the machine that
+// transmitted it.
+func (s *Switch) Capture(out io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	header := []any{
+		uint32(0xa1b2c3d4), // magic number, microsecond timestamps
+		uint16(2),          // major version
+		uint16(4),          // minor version
+		int32(0),           // time zone
+		uint32(0),          // timestamp accuracy
+		uint32(65535),      // snapshot length
+		uint32(1),          // link type: Ethernet
+	}
+
+	for _, field := range header {
+		err := binary.Write(out, binary.LittleEndian, field)
+		if err != nil {
+			return fmt.Errorf("write pcap header: %w", err)
+		}
+	}
+
+	s.capture = out
+	return nil
+}
+
+// forward forwards the frame transmitted by the interface from at the given
+// virtual time.
+func (s *Switch) forward(from *NIC, frame []byte, cycles uint64) {
+	if len(frame) < 14 {
+		return // not an Ethernet frame
+	}
+
+	// The frame may be in the memory of the machine.
+	frame = bytes.Clone(frame)
+
+	s.mu.Lock()
+	s.table[string(frame[6:12])] = from
+	to, known := s.table[string(frame[0:6])]
+	s.log(frame, cycles)
+	nics := s.nics
+	s.mu.Unlock()
+
+	if frame[0]&1 == 0 && known {
+		if to != from {
+			to.enqueue(frame)
+		}
+
+		return
+	}
+
+	for _, nic := range nics {
+		if nic != from {
+			nic.enqueue(frame)
+		}
+	}
+}
+
+// log writes the frame to the capture. On error, capturing stops.
+func (s *Switch) log(frame []byte, cycles uint64) {
+	if s.capture == nil {
+		return
+	}
+
+	record := []any{
+		uint32(cycles / ClockRate),
+		uint32(cycles % ClockRate * 1_000_000 / ClockRate),
+		uint32(len(frame)),
+		uint32(len(frame)),
+		frame,
+	}
+
+	for _, field := range record {
+		err := binary.Write(s.capture, binary.LittleEndian, field)
+		if err != nil {
+			s.capture = nil
+			return
+		}
+	}
+}
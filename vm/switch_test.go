@@ -0,0 +1,40 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestSwitchCapture(t *testing.T) {
+	var capture bytes.Buffer
+	sw := NewSwitch()
+	err := sw.Capture(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mac := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	vm, nic := setUpNIC(t, sw, mac)
+	vm.cycles = 3*ClockRate + 250
+	f := ethernetFrame(net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, mac, "x")
+	send(t, vm, nic, f)
+
+	data := capture.Bytes()
+	if len(data) != 24+16+len(f) {
+		t.Fatalf("got %d bytes, want %d", len(data), 24+16+len(f))
+	}
+	if magic := binary.LittleEndian.Uint32(data); magic != 0xa1b2c3d4 {
+		t.Errorf("got magic %#x, want 0xa1b2c3d4", magic)
+	}
+
+	record := data[24:]
+	sec, usec := binary.LittleEndian.Uint32(record), binary.LittleEndian.Uint32(record[4:])
+	if sec != 3 || usec != 250 {
+		t.Errorf("got timestamp %d.%06d, want 3.000250", sec, usec)
+	}
+	if !bytes.Equal(record[16:], f) {
+		t.Errorf("got frame % x, want % x", record[16:], f)
+	}
+}
@@ -0,0 +1,239 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrPTYUnsupported is returned by OpenPTYUART on systems where pseudo-terminals
+// are not supported.
+var ErrPTYUnsupported = errors.New("pseudo-terminals are not supported")
+
+// PortUART is the default base port of the first UART. Each UART takes
+// UARTPorts consecutive ports, starting at its base port:
+//
+//	base+0  data: reads the next received byte, writes a byte to transmit
+//	base+1  status (UARTRxReady, UARTTxReady, UARTConnected, UARTTxDone)
+//	base+2  control (UARTRxInterrupt, UARTTxInterrupt)
+const (
+	PortUART  = 0x40
+	UARTPorts = 3
+)
+
+// Bits of the UART status register.
+const (
+	UARTRxReady   = 1 << 0 // a received byte can be read
+	UARTTxReady   = 1 << 1 // a byte can be transmitted; always set
+	UARTConnected = 1 << 2 // the host side is connected
+	UARTTxDone    = 1 << 3 // a byte was transmitted; cleared when status is read
+)
+
+// Bits of the UART control register.
+const (
+	UARTRxInterrupt = 1 << 0 // raise the interrupt when data is received
+	UARTTxInterrupt = 1 << 1 // raise the interrupt when a byte is transmitted
+)
+
+// UART is a serial port whose host side is a stream, such as a network
+// connection or a pseudo-terminal. Received data is buffered until the guest
+// reads it. Transmitted bytes are dropped while the host side is not
+// connected.
+type UART struct {
+	// Vector is the interrupt raised for both received and transmitted data.
+	// The status register tells them apart: UARTRxReady is set while received
+	// data is buffered, and UARTTxDone is set if a byte was transmitted since
+	// the status was last read. It is IntUart by default.
+	Vector int
+
+	base     byte
+	control  byte
+	received atomic.Bool // since the last tick
+
+	mu       sync.Mutex
+	rx       []byte
+	txDone   bool
+	conn     io.ReadWriteCloser
+	listener net.Listener
+	closed   bool
+}
+
+// NewUART creates a UART at the base port, connected to conn.
+func NewUART(base byte, conn io.ReadWriteCloser) *UART {
+	u := &UART{Vector: IntUart, base: base}
+	go u.serve(conn)
+	return u
+}
+
+// ListenUART creates a UART at the base port that listens for connections on
+// the network address, for example "unix" and "/tmp/uart.sock" or "tcp" and
+// "127.0.0.1:2323". One connection is served at a time.
+func ListenUART(base byte, network, address string) (*UART, error) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	u := &UART{Vector: IntUart, base: base, listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			u.serve(conn)
+		}
+	}()
+
+	return u, nil
+}
+
+// Addr returns the address the UART listens on, or nil if it does not listen.
+func (u *UART) Addr() net.Addr {
+	if u.listener == nil {
+		return nil
+	}
+
+	return u.listener.Addr()
+}
+
+// serve receives data from conn until it is closed.
+func (u *UART) serve(conn io.ReadWriteCloser) {
+	u.mu.Lock()
+	if u.closed {
+		u.mu.Unlock()
+		conn.Close()
+		return
+	}
+	u.conn = conn
+	u.mu.Unlock()
+
+	buf := make([]byte, 512)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			u.mu.Lock()
+			u.rx = append(u.rx, buf[:n]...)
+			u.mu.Unlock()
+			u.received.Store(true)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	u.mu.Lock()
+	if u.conn == conn {
+		u.conn = nil
+	}
+	u.mu.Unlock()
+	conn.Close()
+}
+
+// Close disconnects the host side and stops listening.
+func (u *UART) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.closed = true
+	var err error
+	if u.listener != nil {
+		err = u.listener.Close()
+	}
+	if u.conn != nil {
+		u.conn.Close()
+		u.conn = nil
+	}
+
+	return err
+}
+
+func (u *UART) Ports() []byte {
+	ports := make([]byte, UARTPorts)
+	for i := range ports {
+		ports[i] = u.base + byte(i)
+	}
+
+	return ports
+}
+
+func (u *UART) In(vm *VM, port byte) byte {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	switch port - u.base {
+	case 0:
+		if len(u.rx) == 0 {
+			return 0
+		}
+
+		b := u.rx[0]
+		u.rx = u.rx[1:]
+		return b
+	case 1:
+		status := byte(UARTTxReady)
+		if len(u.rx) != 0 {
+			status |= UARTRxReady
+		}
+		if u.conn != nil {
+			status |= UARTConnected
+		}
+		if u.txDone {
+			status |= UARTTxDone
+			u.txDone = false
+		}
+
+		return status
+	default:
+		return u.control
+	}
+}
+
+func (u *UART) Out(vm *VM, port byte, value byte) {
+	switch port - u.base {
+	case 0:
+		u.transmit(vm, value)
+		if u.control&UARTTxInterrupt != 0 {
+			vm.interrupt(u.Vector)
+		}
+	case 2:
+		u.control = value
+		u.mu.Lock()
+		pending := len(u.rx) != 0
+		u.mu.Unlock()
+		if u.control&UARTRxInterrupt != 0 && pending {
+			vm.interrupt(u.Vector)
+		}
+	}
+}
+
+func (u *UART) transmit(vm *VM, value byte) {
+	u.mu.Lock()
+	conn := u.conn
+	u.txDone = true
+	u.mu.Unlock()
+	if conn == nil {
+		return
+	}
+
+	_, err := conn.Write([]byte{value})
+	if err != nil {
+		if vm.debug {
+			fmt.Printf("debug: UART at port %#02x failed to transmit: %v\n", u.base, err)
+		}
+
+		// Closing the connection makes serve disconnect it.
+		conn.Close()
+	}
+}
+
+func (u *UART) Tick(vm *VM) {
+	if u.received.Swap(false) && u.control&UARTRxInterrupt != 0 {
+		vm.interrupt(u.Vector)
+	}
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+package vm
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// OpenPTYUART creates a UART at the base port whose host side is a new
+// pseudo-terminal. It returns the path of the terminal, for example
+// /dev/pts/3, to be opened with a terminal program such as screen.
+func OpenPTYUART(base byte) (*UART, string, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", fmt.Errorf("open pseudo-terminal: %w", err)
+	}
+
+	var unlock int32
+	var number uint32
+	err = ioctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock))
+	if err == nil {
+		err = ioctl(master, syscall.TIOCGPTN, unsafe.Pointer(&number))
+	}
+	if err != nil {
+		master.Close()
+		return nil, "", fmt.Errorf("set up pseudo-terminal: %w", err)
+	}
+
+	// Keep the terminal open, so that reading from the master side does not
+	// fail while no terminal program has it open.
+	name := fmt.Sprintf("/dev/pts/%d", number)
+	terminal, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, "", fmt.Errorf("open pseudo-terminal: %w", err)
+	}
+
+	return NewUART(base, &pty{File: master, terminal: terminal}), name, nil
+}
+
+// pty is the master side of a pseudo-terminal.
+type pty struct {
+	*os.File
+	terminal *os.File
+}
+
+func (p *pty) Close() error {
+	p.terminal.Close()
+	return p.File.Close()
+}
+
+func ioctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package vm
+
+// OpenPTYUART creates a UART at the base port whose host side is a new
+// pseudo-terminal. It is only supported on Linux.
+func OpenPTYUART(base byte) (*UART, string, error) {
+	return nil, "", ErrPTYUnsupported
+}
@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitUART runs the UART until the status has the bits set.
+func waitUART(t *testing.T, vm *VM, u *UART, bits byte) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for u.In(vm, u.base+1)&bits != bits {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for status %#x", bits)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	u.Tick(vm)
+}
+
+func TestUART(t *testing.T) {
+	host, guest := net.Pipe()
+	defer host.Close()
+
+	vm := NewVM()
+	u := NewUART(PortUART, guest)
+	defer u.Close()
+	err := vm.AttachDevice(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u.Out(vm, PortUART+2, UARTRxInterrupt|UARTTxInterrupt)
+	go func() { _, _ = host.Write([]byte("hi")) }()
+	waitUART(t, vm, u, UARTConnected|UARTRxReady)
+	if len(vm.interruptQueue) != 1 || vm.interruptQueue[0] != IntUart {
+		t.Errorf("got interrupts %v, want %v", vm.interruptQueue, []int{IntUart})
+	}
+
+	got := make([]byte, 0, 2)
+	for len(got) < 2 {
+		waitUART(t, vm, u, UARTRxReady)
+		got = append(got, u.In(vm, PortUART))
+	}
+	if string(got) != "hi" {
+		t.Errorf("got %q, want %q", got, "hi")
+	}
+	if status := u.In(vm, PortUART+1); status != UARTConnected|UARTTxReady {
+		t.Errorf("got status %#x, want %#x", status, UARTConnected|UARTTxReady)
+	}
+
+	done := make(chan []byte)
+	go func() {
+		buf := make([]byte, 1)
+		_, _ = io.ReadFull(host, buf)
+		done <- buf
+	}()
+	u.Out(vm, PortUART, 'x')
+	if got := <-done; !bytes.Equal(got, []byte("x")) {
+		t.Errorf("host got %q, want %q", got, "x")
+	}
+	if len(vm.interruptQueue) != 2 {
+		t.Errorf("got interrupts %v, want a TX interrupt", vm.interruptQueue)
+	}
+	if status := u.In(vm, PortUART+1); status != UARTConnected|UARTTxReady|UARTTxDone {
+		t.Errorf("got status %#x after transmitting, want %#x", status, UARTConnected|UARTTxReady|UARTTxDone)
+	}
+	if status := u.In(vm, PortUART+1); status&UARTTxDone != 0 {
+		t.Errorf("got status %#x, want UARTTxDone cleared by the previous read", status)
+	}
+}
+
+func TestListenUART(t *testing.T) {
+	testCases := []struct {
+		network string
+		address string
+	}{
+		{network: "tcp", address: "127.0.0.1:0"},
+		{network: "unix", address: filepath.Join(t.TempDir(), "uart.sock")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.network, func(t *testing.T) {
+			vm := NewVM()
+			u, err := ListenUART(PortUART, tc.network, tc.address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer u.Close()
+
+			conn, err := net.Dial(tc.network, u.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			waitUART(t, vm, u, UARTConnected)
+			u.Out(vm, PortUART, 'y')
+			buf := make([]byte, 1)
+			_, err = io.ReadFull(conn, buf)
+			if err != nil || buf[0] != 'y' {
+				t.Errorf("got %q, %v, want %q", buf, err, "y")
+			}
+		})
+	}
+}
+
+func TestPTYUART(t *testing.T) {
+	u, name, err := OpenPTYUART(PortUART)
+	if errors.Is(err, ErrPTYUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+
+	terminal, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer terminal.Close()
+
+	vm := NewVM()
+	_, _ = terminal.Write([]byte("z"))
+	waitUART(t, vm, u, UARTRxReady)
+	if got := u.In(vm, PortUART); got != 'z' {
+		t.Errorf("got %q, want %q", got, 'z')
+	}
+}
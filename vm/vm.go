@@ -6,6 +6,10 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bartekpacia/toyvm/isa"
 )
 
 // gpRegister is a general-purpose register.
@@ -13,25 +17,63 @@ type gpRegister struct {
 	value uint32
 }
 
+// Bits of the flag register.
 const (
-	FlagZF = iota + 1 // zero flag
-	FlagCF            // carry flag
+	FlagZF = 1 << iota // zero flag
+	FlagCF             // carry flag: unsigned carry or borrow
+	FlagSF             // sign flag: bit 31 of the result
+	FlagOF             // overflow flag: signed overflow
 )
 
 const (
 	IntMemoryError   = iota
 	IntDivisionError = iota
 	IntGeneralError  = iota
-
-	IntPit     = 8 // generated by programmable timer
-	IntConsole = 9 // generated by console
+	IntDoubleFault   = iota // generated when an interrupt cannot be delivered
+	IntDisk          = iota // generated by disk controller on completion
+	IntDMA           = iota // generated by DMA controller on completion
+	IntUart          = iota // generated by UARTs
+	IntNic           = iota // generated by network interface on receive
+
+	IntPit           = 8  // generated by programmable timer
+	IntConsole       = 9  // generated by console
+	IntRtc           = 10 // generated by real-time clock alarm
+	IntWatchdog      = 11 // generated by watchdog on first expiry; non-maskable
+	IntMailbox       = 12 // generated by mailbox when a message arrives
+	IntIpi           = 13 // generated by another core
+	IntFloatError    = 14 // generated by invalid floating-point operations
+	IntHostCallError = 15 // generated when a host call fails
 
 	CregIntFirst  = 0x100
 	CregIntLast   = 0x10f
 	CregIntContrl = 0x110
+	CregIntLevel  = 0x111 // vector of the interrupt being serviced
+
+	CregCoreID    = 0x120 // number of this core, read-only
+	CregCoreCount = 0x121 // number of cores, read-only
+	CregIPI       = 0x122 // writing a core number sends it IntIpi
+	CregCoreStart = 0x123 // where cores woken by this one start
+
+	CregExtensions = 0x130 // enabled instruction set extensions
+)
+
+// Bits of the CregIntContrl control register.
+const (
+	IntContrlEnable = 1 << iota // maskable interrupts enabled
+	IntContrlNested             // keep maskable interrupts enabled in handlers
+)
+
+// Bits of the CregExtensions control register. Extensions are disabled at
+// boot, so programs written for the book are unaffected by them.
+const (
+	ExtFloat = 1 << iota // floating-point instructions
 )
 
-var MaskableInterrupts = []int{IntPit, IntConsole}
+// IntLevelNone is the value of CregIntLevel when no interrupt is being
+// serviced.
+const IntLevelNone = 0x10
+
+var MaskableInterrupts = []int{IntDisk, IntDMA, IntUart, IntNic, IntPit, IntConsole, IntRtc, IntMailbox, IntIpi}
 
 type VM struct {
 	memory     *Memory
@@ -41,7 +83,25 @@ type VM struct {
 	sp         *gpRegister  // stack pointer
 	fr         uint32       // flag register
 	terminated bool
+	err        error // error that terminated the machine
 	opcodes    map[byte]opcode
+	ports      map[byte]Device
+	tickers    []Ticker
+	hostCalls  map[uint16]HostCall
+	hooks      hooks
+	cycles     uint64 // virtual time, see Cycles
+	executed   uint64 // instructions
+
+	halted       bool          // by VHLT, until an interrupt can be delivered
+	wakeup       chan struct{} // signalled when an interrupt is raised
+	haltedCycles uint64
+	sleptAt      uint64 // haltedCycles when the machine last slept
+	haltedTime   time.Duration
+
+	machine *Machine    // that the core belongs to, if any
+	parked  atomic.Bool // waiting to be woken by another core
+	wake    chan struct{}
+	io      sync.Locker // held while accessing devices, if not nil
 
 	interruptQueue      []int
 	interruptQueueMutex sync.Mutex
@@ -63,11 +123,13 @@ func NewVM() *VM {
 		memory:     &Memory{mem: make([]byte, 64*1024)}, // 64KB
 		reg:        registers,
 		creg:       make(map[int]int),
-		pc:         &registers[14],
-		sp:         &registers[15],
+		pc:         &registers[isa.PC],
+		sp:         &registers[isa.SP],
 		fr:         0,
 		terminated: false,
-		opcodes:    opcodes,
+		opcodes:    instructionSet(),
+		ports:      make(map[byte]Device),
+		wakeup:     make(chan struct{}, 1),
 
 		interruptQueue:      make([]int, 0),
 		interruptQueueMutex: sync.Mutex{},
@@ -82,8 +144,18 @@ func NewVM() *VM {
 		vm.creg[creg] = 0xffffffff
 	}
 	vm.creg[CregIntContrl] = 0 // Maskable interrupts disabled.
+	vm.creg[CregIntLevel] = IntLevelNone
+
+	// Core registers.
+	vm.creg[CregCoreID] = 0
+	vm.creg[CregCoreCount] = 1
+	vm.creg[CregIPI] = 0
+	vm.creg[CregCoreStart] = 0
+
+	vm.creg[CregExtensions] = 0
 
 	vm.Stdout = os.Stdout
+	_ = vm.AttachDevice(console{})
 
 	return &vm
 }
@@ -92,6 +164,13 @@ func (vm *VM) SetDebug(value bool) {
 	vm.debug = value
 }
 
+// halt terminates the virtual machine with an error that will be returned
+// from Run.
+func (vm *VM) halt(err error) {
+	vm.terminated = true
+	vm.err = err
+}
+
 // crash terminates the virtual machine on critical error
 func (vm *VM) crash() {
 	vm.terminated = true
@@ -103,86 +182,65 @@ func (vm *VM) crash() {
 	}
 }
 
-func (vm *VM) interrupt(interrupt int) {
-	vm.interruptQueueMutex.Lock()
-	defer vm.interruptQueueMutex.Unlock()
-
-	vm.interruptQueue = append(vm.interruptQueue, interrupt)
+// jump performs a relative jump by the imm16 in args (modulo 2^16).
+func (vm *VM) jump(args []byte) {
+	diff := uint32(args[0]) | uint32(args[1])<<8
+	vm.pc.value = (vm.pc.value + diff) & 0xffff
 }
 
-// fetchPendingInterrupt returns a pending interrupt to be processed. If
-// maskable interrupts are disabled, returns a non-maskable interrupt (NMI) if
-// available. If no interrupts are available for processing, returns None.
-func (vm *VM) fetchPendingInterrupt() *int {
-	vm.interruptQueueMutex.Lock()
-	defer vm.interruptQueueMutex.Unlock()
-
-	if len(vm.interruptQueue) == 0 {
-		return nil
+// setFlags sets the flags for the result of an arithmetic instruction. ZF and
+// SF are derived from the result itself.
+func (vm *VM) setFlags(result uint32, carry, overflow bool) {
+	vm.fr &^= FlagZF | FlagCF | FlagSF | FlagOF
+	if result == 0 {
+		vm.fr |= FlagZF
 	}
-
-	// In the disable-interrupts state, we can process only non-maskable interrupts
-	// (faults).
-	if vm.creg[CregIntContrl]&1 == 0 {
-		// Maskable interrupts disabled. Find a non-maskable one.
-		for i, interrupt := range vm.interruptQueue {
-			for _, maskableInterrupt := range MaskableInterrupts {
-				if interrupt == maskableInterrupt {
-					q := vm.interruptQueue
-
-					// pop element at index i
-					_interrupt := q[i]
-					q = append(q[:i], q[i+1:]...)
-					vm.interruptQueue = q
-
-					return &_interrupt
-				}
-			}
-		}
-
-		return nil
+	if result&(1<<31) != 0 {
+		vm.fr |= FlagSF
 	}
+	if carry {
+		vm.fr |= FlagCF
+	}
+	if overflow {
+		vm.fr |= FlagOF
+	}
+}
 
-	// No non-maskable interrupts found.
-
-	// Return the first interrupt available (pop)
-	q := vm.interruptQueue
-	i, queue := q[len(q)-1], q[:len(q)-1]
-	vm.interruptQueue = queue
+// carry returns the carry flag as a number.
+func (vm *VM) carry() uint32 {
+	if vm.fr&FlagCF != 0 {
+		return 1
+	}
 
-	return &i
+	return 0
 }
 
-// processInterruptQueue processes an interrupt (if one is available).
-func (vm *VM) processInterruptQueue() error {
-	i := vm.fetchPendingInterrupt()
+// signedLess reports whether the last comparison found rdst less than rsrc as
+// signed integers, that is, whether SF differs from OF.
+func (vm *VM) signedLess() bool {
+	return (vm.fr&FlagSF != 0) != (vm.fr&FlagOF != 0)
+}
 
-	if i == nil {
-		return nil
+// push decreases SP by 4 and stores the value at the address SP points to.
+func (vm *VM) push(value uint32) error {
+	err := vm.store(uint16(vm.sp.value-4), 4, value)
+	if err != nil {
+		return err
 	}
 
-	// Save context
-	tmpSp := vm.sp.value
-	registerValues := make([]uint32, 0)
-	for _, register := range vm.reg {
-		registerValues = append(registerValues, register.value)
-	}
-	registerValues = append(registerValues, vm.fr)
+	vm.sp.value -= 4
+	return nil
+}
 
-	for _, val := range registerValues {
-		tmpSp -= 4
-		err := vm.memory.StoreDword(uint16(tmpSp), val)
-		if err != nil {
-			// Since there is no way to save the state, and therefore no way to
-			// recover, crash the machine.
-			vm.crash()
-			return fmt.Errorf("failed to store dword: %w", err)
-		}
+// pop fetches the value from the address SP points to and increases SP by 4.
+func (vm *VM) pop() (uint32, error) {
+	value, err := vm.load(uint16(vm.sp.value), 4)
+	if err != nil {
+		return 0, err
 	}
 
-	vm.sp.value = tmpSp
-	vm.pc.value = uint32(vm.creg[CregIntFirst+(*i&0xf)])
-	return nil
+	vm.sp.value += 4
+	return value, nil
 }
 
 func (vm *VM) runSingleStep() error {
@@ -190,6 +248,18 @@ func (vm *VM) runSingleStep() error {
 		fmt.Printf("debug: runSingleStep(), pc: %x\n", vm.pc.value)
 	}
 
+	// A halted machine lets virtual time pass until an interrupt can be
+	// delivered.
+	if vm.halted {
+		if !vm.interruptPending() {
+			vm.haltedCycles++
+			vm.tick()
+			return vm.err
+		}
+
+		vm.halted = false
+	}
+
 	// If there is any interrupt on the queue, we need to know about it now.
 	err := vm.processInterruptQueue()
 	if err != nil {
@@ -216,6 +286,7 @@ func (vm *VM) runSingleStep() error {
 	opcode, ok := vm.opcodes[opcodeByte]
 	if !ok {
 		vm.interrupt(IntGeneralError)
+		return nil
 	}
 
 	length := opcode.length
@@ -225,13 +296,115 @@ func (vm *VM) runSingleStep() error {
 		return fmt.Errorf("failed to fetch arg bytes: %v", err)
 	}
 	if vm.debug {
-		fmt.Printf("debug: fetched opcode %#02x %#v (%d args) % x\n", opcodeByte, opcodes[opcodeByte].mnemonic, length, argBytes)
+		fmt.Printf("debug: fetched opcode %#02x %#v (%d args) % x\n", opcodeByte, opcode.mnemonic, length, argBytes)
+	}
+
+	pc := uint16(vm.pc.value)
+	for _, h := range vm.hooks.instruction {
+		h.BeforeInstruction(vm, pc, opcodeByte)
 	}
 
 	handler := opcode.handler
 	vm.pc.value = vm.pc.value + 1 + uint32(length)
 	handler(vm, argBytes)
-	return nil
+
+	for _, h := range vm.hooks.instruction {
+		h.AfterInstruction(vm, pc, opcodeByte)
+	}
+
+	vm.executed++
+	for range max(opcode.cycles, 1) {
+		vm.tick()
+	}
+	return vm.err
+}
+
+// ClockRate is the number of cycles per second of virtual time (see
+// VM.Cycles). Devices that produce timed output use it to stay
+// deterministic, regardless of how fast the host is. A halted machine lets
+// virtual time pass no faster than this rate.
+const ClockRate = 1_000_000
+
+// tick advances virtual time by one cycle.
+func (vm *VM) tick() {
+	vm.cycles++
+	if len(vm.tickers) == 0 {
+		return
+	}
+
+	if vm.io != nil {
+		vm.io.Lock()
+	}
+	for _, ticker := range vm.tickers {
+		ticker.Tick(vm)
+	}
+	if vm.io != nil {
+		vm.io.Unlock()
+	}
+}
+
+// Cycles returns the number of cycles so far: one for each executed
+// instruction, or more for registered instructions that take longer, and one
+// for each step taken while halted. It is the virtual time of the machine.
+func (vm *VM) Cycles() uint64 {
+	return vm.cycles
+}
+
+// Stats are statistics of a machine.
+type Stats struct {
+	Instructions uint64        // executed instructions
+	Cycles       uint64        // virtual time, see VM.Cycles
+	HaltedCycles uint64        // cycles spent halted
+	HaltedTime   time.Duration // host time spent sleeping while halted
+}
+
+// Stats returns the statistics of the machine.
+func (vm *VM) Stats() Stats {
+	return Stats{
+		Instructions: vm.executed,
+		Cycles:       vm.cycles,
+		HaltedCycles: vm.haltedCycles,
+		HaltedTime:   vm.haltedTime,
+	}
+}
+
+// Reg returns the value of the general-purpose register i, from 0 to 15.
+func (vm *VM) Reg(i int) uint32 {
+	return vm.reg[i].value
+}
+
+// SetReg sets the value of the general-purpose register i, from 0 to 15.
+func (vm *VM) SetReg(i int, value uint32) {
+	vm.reg[i].value = value
+}
+
+// PC returns the program counter.
+func (vm *VM) PC() uint32 {
+	return vm.pc.value
+}
+
+// SP returns the stack pointer.
+func (vm *VM) SP() uint32 {
+	return vm.sp.value
+}
+
+// Flags returns the flag register, a combination of FlagZF, FlagCF, FlagSF
+// and FlagOF.
+func (vm *VM) Flags() uint32 {
+	return vm.fr
+}
+
+// ControlRegister returns the value of the control register n. It reports
+// false if there is no such register, like VCRS does with an interrupt.
+func (vm *VM) ControlRegister(n int) (uint32, bool) {
+	value, ok := vm.creg[n]
+	return uint32(value), ok
+}
+
+// Memory returns the memory of the machine. It implements io.ReaderAt and
+// io.WriterAt.
+func (vm *VM) Memory() *Memory {
+	return vm.memory
 }
 
 func (vm *VM) LoadMemoryFromFile(addr uint16, filename string) error {
@@ -252,7 +425,11 @@ func (vm *VM) Run() error {
 	for !vm.terminated {
 		err := vm.runSingleStep()
 		if err != nil {
-			return fmt.Errorf("run single step: %v", err)
+			return fmt.Errorf("run single step: %w", err)
+		}
+
+		if vm.shouldSleep() {
+			vm.sleep(nil)
 		}
 	}
 
@@ -2,10 +2,13 @@ package vm_test
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"os"
 	"testing"
 
+	"github.com/bartekpacia/toyvm/asm"
+	"github.com/bartekpacia/toyvm/isa"
 	"github.com/bartekpacia/toyvm/vm"
 )
 
@@ -63,3 +66,51 @@ func TestVM(t *testing.T) {
 		})
 	}
 }
+
+func TestAccessors(t *testing.T) {
+	program, err := asm.Assemble([]byte(`
+	vmov r3, r2
+	vset r0, 5
+	vcmp r0, r3
+	vpush r3
+	voff
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	machine := vm.NewVM()
+	_, err = machine.Memory().WriteAt(program, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine.SetReg(2, 9)
+
+	err = machine.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if machine.Reg(0) != 5 || machine.Reg(3) != 9 {
+		t.Errorf("got r0 = %d and r3 = %d, want 5 and 9", machine.Reg(0), machine.Reg(3))
+	}
+	if machine.PC() != uint32(len(program)) || machine.Reg(isa.PC) != machine.PC() {
+		t.Errorf("got pc %#x, want %#x", machine.PC(), len(program))
+	}
+	if machine.Flags() != vm.FlagCF|vm.FlagSF {
+		t.Errorf("got flags %b, want CF and SF", machine.Flags())
+	}
+
+	top := make([]byte, 4)
+	_, err = machine.Memory().ReadAt(top, int64(machine.SP()))
+	if err != nil || machine.SP() != 0x10000-4 || binary.LittleEndian.Uint32(top) != 9 {
+		t.Errorf("got sp %#x with % x on top (%v), want 0xfffc with 9", machine.SP(), top, err)
+	}
+
+	if value, ok := machine.ControlRegister(vm.CregIntLevel); !ok || value != vm.IntLevelNone {
+		t.Errorf("got interrupt level %#x, %t, want %#x", value, ok, vm.IntLevelNone)
+	}
+	if _, ok := machine.ControlRegister(0x999); ok {
+		t.Error("got a control register 0x999")
+	}
+}
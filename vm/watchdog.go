@@ -0,0 +1,120 @@
+package vm
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrWatchdog is returned by Run when the watchdog expires twice without being
+// kicked.
+var ErrWatchdog = errors.New("watchdog expired")
+
+// Watchdog ports. The timeout is a 32-bit number of ticks, written low byte
+// first. A tick is a cycle or, for a wall-clock watchdog, a millisecond.
+const (
+	PortWatchdogTimeout = 0xd0 // 0xd0–0xd3
+	PortWatchdogControl = 0xd4
+)
+
+// Commands written to PortWatchdogControl. Reading the port returns the
+// WatchdogArmed and WatchdogExpired status bits.
+const (
+	WatchdogDisarm = 0
+	WatchdogArm    = 1 // arms the watchdog and kicks it
+	WatchdogKick   = 2
+)
+
+// Status bits read from PortWatchdogControl.
+const (
+	WatchdogArmed   = 1 << 0
+	WatchdogExpired = 1 << 1 // expired once since the last kick
+)
+
+// Watchdog is a watchdog timer device. Once armed, the guest must kick it
+// before the timeout passes. The first time it expires, the watchdog raises
+// IntWatchdog and starts counting again. If it expires a second time without
+// being kicked, the machine halts with ErrWatchdog.
+type Watchdog struct {
+	wallClock bool
+	timeout   uint32
+	status    byte
+	deadline  uint64    // in cycles
+	wallTime  time.Time // deadline for a wall-clock watchdog
+}
+
+// NewWatchdog creates a watchdog that measures the timeout in cycles.
+func NewWatchdog() *Watchdog {
+	return &Watchdog{}
+}
+
+// NewWallClockWatchdog creates a watchdog that measures the timeout in
+// milliseconds of host time.
+func NewWallClockWatchdog() *Watchdog {
+	return &Watchdog{wallClock: true}
+}
+
+func (w *Watchdog) Ports() []byte {
+	return []byte{PortWatchdogTimeout, PortWatchdogTimeout + 1, PortWatchdogTimeout + 2, PortWatchdogTimeout + 3, PortWatchdogControl}
+}
+
+func (w *Watchdog) In(vm *VM, port byte) byte {
+	if port == PortWatchdogControl {
+		return w.status
+	}
+
+	return byte(w.timeout >> (8 * (port - PortWatchdogTimeout)))
+}
+
+func (w *Watchdog) Out(vm *VM, port byte, value byte) {
+	if port != PortWatchdogControl {
+		shift := 8 * (port - PortWatchdogTimeout)
+		w.timeout = w.timeout&^(0xff<<shift) | uint32(value)<<shift
+		return
+	}
+
+	switch value {
+	case WatchdogDisarm:
+		w.status = 0
+	case WatchdogArm:
+		w.status = WatchdogArmed
+		w.kick(vm)
+	case WatchdogKick:
+		if w.status&WatchdogArmed != 0 {
+			w.status = WatchdogArmed
+			w.kick(vm)
+		}
+	}
+}
+
+func (w *Watchdog) Tick(vm *VM) {
+	if w.status&WatchdogArmed == 0 || !w.expired(vm) {
+		return
+	}
+
+	if w.status&WatchdogExpired != 0 {
+		w.status = 0
+		vm.halt(ErrWatchdog)
+		return
+	}
+
+	w.status |= WatchdogExpired
+	w.kick(vm)
+	vm.interrupt(IntWatchdog)
+}
+
+// kick starts counting the timeout again.
+func (w *Watchdog) kick(vm *VM) {
+	if w.wallClock {
+		w.wallTime = time.Now().Add(time.Duration(w.timeout) * time.Millisecond)
+	} else {
+		w.deadline = vm.cycles + uint64(w.timeout)
+	}
+}
+
+func (w *Watchdog) expired(vm *VM) bool {
+	if w.wallClock {
+		return !time.Now().Before(w.wallTime)
+	}
+
+	return vm.cycles >= w.deadline
+}
@@ -0,0 +1,73 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWatchdogExpires(t *testing.T) {
+	vm := NewVM()
+	wd := NewWatchdog()
+	_ = vm.AttachDevice(wd)
+	wd.Out(vm, PortWatchdogTimeout, 10)
+	wd.Out(vm, PortWatchdogControl, WatchdogArm)
+
+	for range 10 {
+		err := vm.runSingleStep()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(vm.interruptQueue) != 1 || vm.interruptQueue[0] != IntWatchdog {
+		t.Errorf("got interrupts %v, want %v", vm.interruptQueue, []int{IntWatchdog})
+	}
+	if got := wd.In(vm, PortWatchdogControl); got != WatchdogArmed|WatchdogExpired {
+		t.Errorf("got status %#x, want %#x", got, WatchdogArmed|WatchdogExpired)
+	}
+
+	// There is no handler, so the interrupt is dropped and the watchdog
+	// expires again.
+	err := vm.Run()
+	if !errors.Is(err, ErrWatchdog) {
+		t.Errorf("got error %v, want %v", err, ErrWatchdog)
+	}
+	if vm.cycles != 20 {
+		t.Errorf("got %d cycles, want 20", vm.cycles)
+	}
+}
+
+func TestWatchdogKick(t *testing.T) {
+	vm := NewVM()
+	wd := NewWatchdog()
+	_ = vm.AttachDevice(wd)
+	wd.Out(vm, PortWatchdogTimeout, 10)
+	wd.Out(vm, PortWatchdogControl, WatchdogArm)
+
+	for i := range 100 {
+		if i%5 == 0 {
+			wd.Out(vm, PortWatchdogControl, WatchdogKick)
+		}
+
+		err := vm.runSingleStep()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(vm.interruptQueue) != 0 {
+		t.Errorf("got interrupts %v, want none", vm.interruptQueue)
+	}
+
+	wd.Out(vm, PortWatchdogControl, WatchdogDisarm)
+	for range 100 {
+		err := vm.runSingleStep()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(vm.interruptQueue) != 0 {
+		t.Errorf("got interrupts %v after disarming, want none", vm.interruptQueue)
+	}
+}